@@ -45,6 +45,9 @@ func main() {
 	if role == "slave" {
 		masterHost, masterPort := replicaOf, flag.Args()[len(flag.Args())-1]
 		go rn.ConnectToMaster(masterHost, masterPort)
+	} else {
+		// replicas leave removing expired keys to their master
+		go rn.ActiveExpire()
 	}
 
 	l, err := net.Listen("tcp", fmt.Sprintf("0.0.0.0:%s", port))
@@ -0,0 +1,115 @@
+package parser
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// SplitArgs splits an inline command into its arguments the way redis-cli
+// and the Redis server do. Arguments are separated by spaces and may be
+// wrapped in double quotes, which understand the usual backslash escapes and
+// \xHH, or in single quotes, which only understand \'.
+func SplitArgs(line string) ([]string, error) {
+	args := []string{}
+	i := 0
+
+	for {
+		for i < len(line) && isSpace(line[i]) {
+			i++
+		}
+
+		if i == len(line) {
+			return args, nil
+		}
+
+		var arg strings.Builder
+		inDouble, inSingle := false, false
+
+	word:
+		for {
+			switch {
+			case inDouble:
+				if i == len(line) {
+					return nil, fmt.Errorf("%w: unbalanced quotes in request", ErrProtocol)
+				}
+
+				if line[i] == '\\' && i+3 < len(line) && line[i+1] == 'x' &&
+					isHexDigit(line[i+2]) && isHexDigit(line[i+3]) {
+					b, _ := strconv.ParseUint(line[i+2:i+4], 16, 8)
+					arg.WriteByte(byte(b))
+					i += 3
+				} else if line[i] == '\\' && i+1 < len(line) {
+					i++
+					switch line[i] {
+					case 'n':
+						arg.WriteByte('\n')
+					case 'r':
+						arg.WriteByte('\r')
+					case 't':
+						arg.WriteByte('\t')
+					case 'b':
+						arg.WriteByte('\b')
+					case 'a':
+						arg.WriteByte('\a')
+					default:
+						arg.WriteByte(line[i])
+					}
+				} else if line[i] == '"' {
+					// the closing quote must be followed by a space or nothing
+					if i+1 < len(line) && !isSpace(line[i+1]) {
+						return nil, fmt.Errorf("%w: unbalanced quotes in request", ErrProtocol)
+					}
+					i++
+					break word
+				} else {
+					arg.WriteByte(line[i])
+				}
+
+			case inSingle:
+				if i == len(line) {
+					return nil, fmt.Errorf("%w: unbalanced quotes in request", ErrProtocol)
+				}
+
+				if line[i] == '\\' && i+1 < len(line) && line[i+1] == '\'' {
+					arg.WriteByte('\'')
+					i++
+				} else if line[i] == '\'' {
+					if i+1 < len(line) && !isSpace(line[i+1]) {
+						return nil, fmt.Errorf("%w: unbalanced quotes in request", ErrProtocol)
+					}
+					i++
+					break word
+				} else {
+					arg.WriteByte(line[i])
+				}
+
+			default:
+				if i == len(line) || isSpace(line[i]) {
+					break word
+				}
+
+				switch line[i] {
+				case '"':
+					inDouble = true
+				case '\'':
+					inSingle = true
+				default:
+					arg.WriteByte(line[i])
+				}
+			}
+
+			i++
+		}
+
+		args = append(args, arg.String())
+	}
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\v' || c == '\f'
+}
+
+func isHexDigit(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
@@ -4,7 +4,6 @@ import (
 	"encoding/base64"
 	"errors"
 	"fmt"
-	"strconv"
 	"strings"
 )
 
@@ -25,73 +24,84 @@ func EncodeInteger(value string) string {
 }
 
 func EncodeBulkString(value string) string {
-	if len(value) == 0 {
-		return "$-1\r\n"
-	}
 	return fmt.Sprintf("$%d\r\n%s\r\n", len(value), value)
 }
 
+// EncodeNullBulkString encodes a missing value. Unlike an empty string, which
+// EncodeBulkString handles, it tells the client that there is nothing there.
+func EncodeNullBulkString() string {
+	return "$-1\r\n"
+}
+
 func EncodeArray(values []string) string {
-	str := ""
+	var str strings.Builder
 
+	fmt.Fprintf(&str, "*%d\r\n", len(values))
 	for _, value := range values {
-		str = fmt.Sprintf("%s%s", str, EncodeBulkString(value))
+		str.WriteString(EncodeBulkString(value))
 	}
 
-	return fmt.Sprintf("*%d\r\n%s", len(values), str)
+	return str.String()
 }
 
-func EncodeRDBFile(rdb string) (string, error) {
-	binData, err := base64.StdEncoding.DecodeString(rdb)
-	if err != nil {
-		return "", errors.New("failed to parse RDB file")
-	}
-	return fmt.Sprintf("$%d\r\n%s\r\n", len(binData)+2, binData), nil
+// EncodeNestedArray builds an array out of values that are already encoded,
+// so its elements can be of mixed types.
+func EncodeNestedArray(elements []string) string {
+	return fmt.Sprintf("*%d\r\n%s", len(elements), strings.Join(elements, ""))
 }
 
-func DecodeBulkString(tokens [][]byte) ([]byte, error) {
-	header, content := tokens[0], tokens[1]
+func EncodeNull() string {
+	return "_\r\n"
+}
 
-	length, err := strconv.Atoi(string(header[1:]))
-	if err != nil {
-		return []byte{}, errors.New(
-			"wrong request. error converting to int in func decodeBulkString",
-		)
+func EncodeBoolean(value bool) string {
+	if value {
+		return "#t\r\n"
 	}
+	return "#f\r\n"
+}
 
-	if len(content) != length {
-		return []byte{}, errors.New("wrong request. lengths are not equal in func decodeBulkString")
-	}
+func EncodeDouble(value float64) string {
+	return fmt.Sprintf(",%s\r\n", FormatDouble(value))
+}
 
-	return content, nil
+func EncodeBigNumber(value string) string {
+	return fmt.Sprintf("(%s\r\n", value)
 }
 
-func DecodeArray(tokens [][]byte) (string, [][]byte, error) {
-	header := string(tokens[0])
+// EncodeVerbatimString encodes value with a three letter format such as "txt"
+// or "mkd" that tells the client how to display it.
+func EncodeVerbatimString(format string, value string) string {
+	return fmt.Sprintf("=%d\r\n%s:%s\r\n", len(value)+4, format, value)
+}
 
-	n, err := strconv.Atoi(header[1:])
-	if err != nil {
-		fmt.Println("header: ", header)
-		return "", [][]byte{}, fmt.Errorf(
-			"wrong request. error converting to int in func decodeArray: %#v",
-			header[1:],
-		)
-	}
+// EncodeMap builds a map out of already encoded elements laid out as
+// alternating keys and values.
+func EncodeMap(elements []string) string {
+	return fmt.Sprintf("%%%d\r\n%s", len(elements)/2, strings.Join(elements, ""))
+}
 
-	var name string
-	var args [][]byte
-	for i := range n {
-		val, err := DecodeBulkString(tokens[2*i+1 : 2*i+3])
-		if err != nil {
-			return "", [][]byte{}, err
-		}
-
-		if i == 0 {
-			name = strings.ToLower(string(val))
-		} else {
-			args = append(args, val)
-		}
-	}
+// EncodeSet builds a set out of already encoded elements.
+func EncodeSet(elements []string) string {
+	return fmt.Sprintf("~%d\r\n%s", len(elements), strings.Join(elements, ""))
+}
+
+// EncodePush builds an out of band push message out of already encoded
+// elements.
+func EncodePush(elements []string) string {
+	return fmt.Sprintf(">%d\r\n%s", len(elements), strings.Join(elements, ""))
+}
 
-	return name, args, nil
+// EncodeAttribute builds an attribute map out of already encoded keys and
+// values. It must be followed by the reply it describes.
+func EncodeAttribute(elements []string) string {
+	return fmt.Sprintf("|%d\r\n%s", len(elements)/2, strings.Join(elements, ""))
+}
+
+func EncodeRDBFile(rdb string) (string, error) {
+	binData, err := base64.StdEncoding.DecodeString(rdb)
+	if err != nil {
+		return "", errors.New("failed to parse RDB file")
+	}
+	return fmt.Sprintf("$%d\r\n%s\r\n", len(binData)+2, binData), nil
 }
@@ -0,0 +1,296 @@
+package parser
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// MaxBulkLength is the largest bulk string the reader accepts, matching the
+// default proto-max-bulk-len of Redis.
+const MaxBulkLength = 512 * 1024 * 1024
+
+// maxNestingDepth bounds how deeply aggregates may be nested, so a peer
+// cannot make the reader recurse without limit.
+const maxNestingDepth = 128
+
+// bulkChunkSize is how much of a bulk string the reader makes room for at a
+// time, so memory grows as the payload arrives rather than as its header
+// announces.
+const bulkChunkSize = 64 * 1024
+
+// maxLineLength bounds the header lines (type byte, length and CRLF) so a
+// peer that never sends a newline cannot grow the buffer without limit.
+const maxLineLength = 64 * 1024
+
+var ErrProtocol = errors.New("Protocol error")
+
+// Value is a single decoded RESP2 or RESP3 value. Scalar types keep their
+// payload in Str, aggregates keep their elements in Array with maps and
+// attributes laid out as alternating keys and values. Attrs holds the
+// attribute map that preceded the value, if any.
+type Value struct {
+	Type  byte
+	Str   []byte
+	Array []Value
+	Attrs []Value
+	Null  bool
+}
+
+// Reader decodes RESP values from a stream, blocking until a complete frame
+// has arrived no matter how it was split across reads.
+type Reader struct {
+	rd *bufio.Reader
+}
+
+func NewReader(rd io.Reader) *Reader {
+	return &Reader{rd: bufio.NewReader(rd)}
+}
+
+// Buffered returns the number of bytes that have been received but not yet
+// decoded.
+func (r *Reader) Buffered() int {
+	return r.rd.Buffered()
+}
+
+// Wait blocks until there is something to read, without consuming it. It
+// returns the error reading failed with otherwise, such as io.EOF once the
+// peer closed the connection.
+func (r *Reader) Wait() error {
+	_, err := r.rd.Peek(1)
+	return err
+}
+
+// ReadValue reads the next complete value and returns it together with the
+// number of bytes it occupied on the wire.
+func (r *Reader) ReadValue() (Value, int, error) {
+	return r.readValue(0)
+}
+
+func (r *Reader) readValue(depth int) (Value, int, error) {
+	if depth > maxNestingDepth {
+		return Value{}, 0, fmt.Errorf("%w: too deeply nested", ErrProtocol)
+	}
+
+	line, err := r.readLine()
+	if err != nil {
+		return Value{}, 0, err
+	}
+
+	if len(line) == 2 {
+		return Value{}, 0, fmt.Errorf("%w: empty line", ErrProtocol)
+	}
+
+	n := len(line)
+	payload := line[1 : len(line)-2]
+
+	switch line[0] {
+	case '+', '-', ':', '#', ',', '(':
+		return Value{Type: line[0], Str: payload}, n, nil
+
+	case '_':
+		return Value{Type: '_', Null: true}, n, nil
+
+	case '$', '=', '!':
+		length, err := parseLength(payload, MaxBulkLength)
+		if err != nil {
+			return Value{}, 0, err
+		}
+
+		if length == -1 {
+			return Value{Type: line[0], Null: true}, n, nil
+		}
+
+		str, err := r.readBulk(length)
+		if err != nil {
+			return Value{}, 0, err
+		}
+
+		return Value{Type: line[0], Str: str}, n + length + 2, nil
+
+	case '*', '~', '>', '%', '|':
+		count, err := parseLength(payload, MaxBulkLength)
+		if err != nil {
+			return Value{}, 0, err
+		}
+
+		if count == -1 {
+			return Value{Type: line[0], Null: true}, n, nil
+		}
+
+		if line[0] == '%' || line[0] == '|' {
+			count *= 2
+		}
+
+		array := make([]Value, 0, min(count, 1024))
+		for range count {
+			el, elN, err := r.readValue(depth + 1)
+			if err != nil {
+				return Value{}, 0, err
+			}
+
+			array = append(array, el)
+			n += elN
+		}
+
+		if line[0] == '|' {
+			value, valueN, err := r.readValue(depth + 1)
+			if err != nil {
+				return Value{}, 0, err
+			}
+
+			value.Attrs = array
+			return value, n + valueN, nil
+		}
+
+		return Value{Type: line[0], Array: array}, n, nil
+
+	default:
+		return Value{}, 0, fmt.Errorf("%w: unexpected type byte %q", ErrProtocol, line[0])
+	}
+}
+
+// ReadRequest reads the next request sent by a client. Requests are normally
+// arrays of bulk strings, anything else is parsed as an inline command made
+// of space separated words, which is what a user typing into telnet or
+// netcat sends. Inline commands are returned as arrays of bulk strings too.
+func (r *Reader) ReadRequest() (Value, int, error) {
+	first, err := r.rd.Peek(1)
+	if err != nil {
+		return Value{}, 0, err
+	}
+
+	if first[0] == '*' {
+		return r.ReadValue()
+	}
+
+	line, err := r.readRawLine()
+	if err != nil {
+		if errors.Is(err, ErrProtocol) {
+			return Value{}, 0, fmt.Errorf("%w: too big inline request", ErrProtocol)
+		}
+		return Value{}, 0, err
+	}
+
+	words, err := SplitArgs(string(bytes.TrimRight(line, "\r\n")))
+	if err != nil {
+		return Value{}, 0, err
+	}
+
+	array := make([]Value, 0, len(words))
+	for _, word := range words {
+		array = append(array, Value{Type: '$', Str: []byte(word)})
+	}
+
+	return Value{Type: '*', Array: array}, len(line), nil
+}
+
+// ReadRDB reads an RDB file sent by a master after FULLRESYNC. It is framed
+// like a bulk string but is not followed by a CRLF.
+func (r *Reader) ReadRDB() ([]byte, error) {
+	line, err := r.readLine()
+	if err != nil {
+		return nil, err
+	}
+
+	if line[0] != '$' {
+		return nil, fmt.Errorf("%w: expected '$' before RDB file, got %q", ErrProtocol, line[0])
+	}
+
+	length, err := parseLength(line[1:len(line)-2], MaxBulkLength)
+	if err != nil {
+		return nil, err
+	}
+
+	if length < 0 {
+		return nil, fmt.Errorf("%w: invalid RDB file length", ErrProtocol)
+	}
+
+	return r.readN(length)
+}
+
+// readLine returns the next line including its trailing CRLF.
+func (r *Reader) readLine() ([]byte, error) {
+	line, err := r.readRawLine()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(line) < 2 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("%w: line not terminated by CRLF", ErrProtocol)
+	}
+
+	return line, nil
+}
+
+// readRawLine returns the next line including its trailing newline, which may
+// or may not be preceded by a carriage return.
+func (r *Reader) readRawLine() ([]byte, error) {
+	var line []byte
+
+	for {
+		chunk, err := r.rd.ReadSlice('\n')
+		line = append(line, chunk...)
+
+		if err == nil {
+			break
+		}
+
+		if !errors.Is(err, bufio.ErrBufferFull) {
+			if len(line) > 0 && errors.Is(err, io.EOF) {
+				return nil, io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+
+		if len(line) > maxLineLength {
+			return nil, fmt.Errorf("%w: too big line", ErrProtocol)
+		}
+	}
+
+	return line, nil
+}
+
+func (r *Reader) readBulk(length int) ([]byte, error) {
+	buf, err := r.readN(length + 2)
+	if err != nil {
+		return nil, err
+	}
+
+	if !bytes.HasSuffix(buf, []byte("\r\n")) {
+		return nil, fmt.Errorf("%w: bulk string not terminated by CRLF", ErrProtocol)
+	}
+
+	return buf[:length], nil
+}
+
+// readN reads exactly n bytes, growing the buffer in chunks of at most
+// bulkChunkSize as they arrive.
+func (r *Reader) readN(n int) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.Grow(min(n, bulkChunkSize))
+
+	for buf.Len() < n {
+		chunk := min(n-buf.Len(), bulkChunkSize)
+		if _, err := io.CopyN(&buf, r.rd, int64(chunk)); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil, io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+	}
+
+	return buf.Bytes(), nil
+}
+
+func parseLength(value []byte, limit int) (int, error) {
+	length, err := strconv.Atoi(string(value))
+	if err != nil || length < -1 || length > limit {
+		return 0, fmt.Errorf("%w: invalid length %q", ErrProtocol, value)
+	}
+
+	return length, nil
+}
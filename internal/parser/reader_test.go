@@ -0,0 +1,164 @@
+package parser
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestReadValue(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  Value
+		n     int
+	}{
+		{
+			name:  "simple string",
+			input: "+OK\r\n",
+			want:  Value{Type: '+', Str: []byte("OK")},
+			n:     5,
+		},
+		{
+			name:  "bulk string",
+			input: "$5\r\nhello\r\n",
+			want:  Value{Type: '$', Str: []byte("hello")},
+			n:     11,
+		},
+		{
+			name:  "bulk string holding CRLF",
+			input: "$4\r\na\r\nb\r\n",
+			want:  Value{Type: '$', Str: []byte("a\r\nb")},
+			n:     10,
+		},
+		{
+			name:  "null bulk string",
+			input: "$-1\r\n",
+			want:  Value{Type: '$', Null: true},
+			n:     5,
+		},
+		{
+			name:  "array",
+			input: "*2\r\n$3\r\nGET\r\n:42\r\n",
+			want: Value{Type: '*', Array: []Value{
+				{Type: '$', Str: []byte("GET")},
+				{Type: ':', Str: []byte("42")},
+			}},
+			n: 18,
+		},
+		{
+			name:  "map",
+			input: "%1\r\n+key\r\n#t\r\n",
+			want: Value{Type: '%', Array: []Value{
+				{Type: '+', Str: []byte("key")},
+				{Type: '#', Str: []byte("t")},
+			}},
+			n: 14,
+		},
+		{
+			name:  "attribute",
+			input: "|1\r\n+ttl\r\n:3\r\n+value\r\n",
+			want: Value{
+				Type: '+',
+				Str:  []byte("value"),
+				Attrs: []Value{
+					{Type: '+', Str: []byte("ttl")},
+					{Type: ':', Str: []byte("3")},
+				},
+			},
+			n: 22,
+		},
+		{
+			name:  "nested as deep as allowed",
+			input: strings.Repeat("*1\r\n", maxNestingDepth) + "_\r\n",
+			want:  nested(maxNestingDepth),
+			n:     4*maxNestingDepth + 3,
+		},
+	}
+
+	readers := []struct {
+		name string
+		wrap func(io.Reader) io.Reader
+	}{
+		{"whole", func(r io.Reader) io.Reader { return r }},
+		{"one byte at a time", iotest.OneByteReader},
+		{"half at a time", iotest.HalfReader},
+	}
+
+	for _, tt := range tests {
+		for _, rd := range readers {
+			t.Run(tt.name+"/"+rd.name, func(t *testing.T) {
+				r := NewReader(rd.wrap(strings.NewReader(tt.input)))
+
+				got, n, err := r.ReadValue()
+				if err != nil {
+					t.Fatalf("ReadValue() error = %v", err)
+				}
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("ReadValue() = %+v, want %+v", got, tt.want)
+				}
+				if n != tt.n {
+					t.Errorf("ReadValue() n = %d, want %d", n, tt.n)
+				}
+			})
+		}
+	}
+}
+
+func nested(depth int) Value {
+	if depth == 0 {
+		return Value{Type: '_', Null: true}
+	}
+	return Value{Type: '*', Array: []Value{nested(depth - 1)}}
+}
+
+func TestReadValueErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  error
+	}{
+		{"bulk longer than allowed", "$536870913\r\n", ErrProtocol},
+		{"negative length", "$-2\r\n", ErrProtocol},
+		{"array longer than allowed", "*536870913\r\n", ErrProtocol},
+		{"line longer than allowed", "+" + strings.Repeat("a", 2*maxLineLength) + "\r\n", ErrProtocol},
+		{"line without CR", "+OK\n", ErrProtocol},
+		{"unknown type byte", "?x\r\n", ErrProtocol},
+		{"bulk without CRLF", "$2\r\nabcd", ErrProtocol},
+		{"nested too deep", strings.Repeat("*1\r\n", maxNestingDepth+1) + "_\r\n", ErrProtocol},
+		{"truncated line", "+OK", io.ErrUnexpectedEOF},
+		{"truncated bulk", "$5\r\nab", io.ErrUnexpectedEOF},
+		{"truncated array", "*2\r\n:1\r\n", io.EOF},
+		{"nothing", "", io.EOF},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewReader(strings.NewReader(tt.input))
+
+			if _, _, err := r.ReadValue(); !errors.Is(err, tt.want) {
+				t.Errorf("ReadValue() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadValueAnnouncedLengthDoesNotAllocate(t *testing.T) {
+	r := NewReader(strings.NewReader("$536870911\r\nabc"))
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+
+	if _, _, err := r.ReadValue(); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("ReadValue() error = %v, want %v", err, io.ErrUnexpectedEOF)
+	}
+
+	runtime.ReadMemStats(&after)
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 1<<20 {
+		t.Errorf("ReadValue() allocated %d bytes for a 3 byte payload", allocated)
+	}
+}
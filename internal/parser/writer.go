@@ -0,0 +1,207 @@
+package parser
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"strconv"
+)
+
+// Writer encodes replies straight into a buffered connection. Nothing reaches
+// the peer until Flush is called, which lets a whole pipeline of replies go
+// out in a single write.
+//
+// Types that only exist in RESP3 fall back to their closest RESP2 encoding
+// when Protocol is 2, the same way Redis does it. Write errors are sticky and
+// reported by Flush.
+type Writer struct {
+	wr       *bufio.Writer
+	buf      []byte
+	Protocol int
+}
+
+func NewWriter(wr io.Writer) *Writer {
+	return &Writer{
+		wr:       bufio.NewWriter(wr),
+		buf:      make([]byte, 0, 32),
+		Protocol: 2,
+	}
+}
+
+func (w *Writer) Flush() error {
+	return w.wr.Flush()
+}
+
+// WriteRaw writes payload as is, for frames that are not regular replies.
+func (w *Writer) WriteRaw(payload []byte) {
+	w.wr.Write(payload)
+}
+
+func (w *Writer) WriteSimpleString(value string) {
+	w.writeLine('+', value)
+}
+
+func (w *Writer) WriteError(message string) {
+	w.writeLine('-', message)
+}
+
+func (w *Writer) WriteInteger(value int64) {
+	w.writeHeader(':', value)
+}
+
+func (w *Writer) WriteBulkString(value string) {
+	w.writeHeader('$', int64(len(value)))
+	w.wr.WriteString(value)
+	w.wr.WriteString("\r\n")
+}
+
+func (w *Writer) WriteBulk(value []byte) {
+	w.writeHeader('$', int64(len(value)))
+	w.wr.Write(value)
+	w.wr.WriteString("\r\n")
+}
+
+// WriteBulkStrings writes an array made of bulk strings.
+func (w *Writer) WriteBulkStrings(values []string) {
+	w.WriteArray(len(values))
+	for _, value := range values {
+		w.WriteBulkString(value)
+	}
+}
+
+// WriteNull writes a missing value, which RESP2 spells as a null bulk string.
+func (w *Writer) WriteNull() {
+	if w.Protocol == 3 {
+		w.wr.WriteString("_\r\n")
+		return
+	}
+	w.wr.WriteString("$-1\r\n")
+}
+
+// WriteNullArray writes a missing aggregate, which RESP2 spells as a null
+// array.
+func (w *Writer) WriteNullArray() {
+	if w.Protocol == 3 {
+		w.wr.WriteString("_\r\n")
+		return
+	}
+	w.wr.WriteString("*-1\r\n")
+}
+
+// WriteArray writes the header of an array, the caller then writes its n
+// elements.
+func (w *Writer) WriteArray(n int) {
+	w.writeHeader('*', int64(n))
+}
+
+// WriteMap writes the header of a map with n key value pairs, which RESP2
+// flattens into an array of 2n elements.
+func (w *Writer) WriteMap(n int) {
+	if w.Protocol == 3 {
+		w.writeHeader('%', int64(n))
+		return
+	}
+	w.writeHeader('*', int64(2*n))
+}
+
+// WriteSet writes the header of a set with n members.
+func (w *Writer) WriteSet(n int) {
+	if w.Protocol == 3 {
+		w.writeHeader('~', int64(n))
+		return
+	}
+	w.writeHeader('*', int64(n))
+}
+
+// WritePush writes the header of an out of band push message with n
+// elements.
+func (w *Writer) WritePush(n int) {
+	if w.Protocol == 3 {
+		w.writeHeader('>', int64(n))
+		return
+	}
+	w.writeHeader('*', int64(n))
+}
+
+// WriteAttribute writes the header of an attribute map with n key value
+// pairs. RESP2 has no attributes, so the caller should not write the pairs
+// when Protocol is 2.
+func (w *Writer) WriteAttribute(n int) {
+	if w.Protocol == 3 {
+		w.writeHeader('|', int64(n))
+	}
+}
+
+func (w *Writer) WriteDouble(value float64) {
+	if w.Protocol == 3 {
+		w.writeLine(',', FormatDouble(value))
+		return
+	}
+	w.WriteBulkString(FormatDouble(value))
+}
+
+func (w *Writer) WriteBoolean(value bool) {
+	if w.Protocol == 3 {
+		if value {
+			w.wr.WriteString("#t\r\n")
+		} else {
+			w.wr.WriteString("#f\r\n")
+		}
+		return
+	}
+
+	if value {
+		w.WriteInteger(1)
+	} else {
+		w.WriteInteger(0)
+	}
+}
+
+func (w *Writer) WriteBigNumber(value string) {
+	if w.Protocol == 3 {
+		w.writeLine('(', value)
+		return
+	}
+	w.WriteBulkString(value)
+}
+
+// WriteVerbatimString writes value with a three letter format such as "txt"
+// that tells RESP3 clients how to display it.
+func (w *Writer) WriteVerbatimString(format string, value string) {
+	if w.Protocol == 3 {
+		w.writeHeader('=', int64(len(value)+4))
+		w.wr.WriteString(format)
+		w.wr.WriteByte(':')
+		w.wr.WriteString(value)
+		w.wr.WriteString("\r\n")
+		return
+	}
+	w.WriteBulkString(value)
+}
+
+func (w *Writer) writeLine(prefix byte, value string) {
+	w.wr.WriteByte(prefix)
+	w.wr.WriteString(value)
+	w.wr.WriteString("\r\n")
+}
+
+func (w *Writer) writeHeader(prefix byte, n int64) {
+	w.buf = append(w.buf[:0], prefix)
+	w.buf = strconv.AppendInt(w.buf, n, 10)
+	w.buf = append(w.buf, '\r', '\n')
+	w.wr.Write(w.buf)
+}
+
+// FormatDouble formats value the way Redis prints scores and floats.
+func FormatDouble(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "inf"
+	case math.IsInf(value, -1):
+		return "-inf"
+	case math.IsNaN(value):
+		return "nan"
+	}
+
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
@@ -6,15 +6,19 @@ import (
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"strconv"
 
 	"nishojib/goredis/internal/types"
 )
 
+// RDBValue is a key read from an RDB file. Expiry is the unix time in
+// milliseconds it expires at, or -1 when it has no time to live.
 type RDBValue struct {
-	Name string
-	Item types.Item
+	Name   string
+	Item   types.Item
+	Expiry int64
 }
 
 func ParseRDBFile(dir string, filename string) ([]RDBValue, error) {
@@ -108,9 +112,11 @@ func ParseRDBFile(dir string, filename string) ([]RDBValue, error) {
 				return []RDBValue{}, fmt.Errorf("error reading value: %s", err.Error())
 			}
 
+			// the expiry is a unix time in seconds
 			values = append(values, RDBValue{
-				Name: key,
-				Item: types.NewItem(val, "string", int64(binary.LittleEndian.Uint64(expiryTime))),
+				Name:   key,
+				Item:   types.NewItem(val, "string"),
+				Expiry: int64(binary.LittleEndian.Uint32(expiryTime)) * 1000,
 			})
 
 		case 0xFC:
@@ -138,12 +144,9 @@ func ParseRDBFile(dir string, filename string) ([]RDBValue, error) {
 			}
 
 			values = append(values, RDBValue{
-				Name: key,
-				Item: types.Item{
-					Value:  val,
-					Type:   "string",
-					Expiry: int64(binary.LittleEndian.Uint64(milliseconds)),
-				},
+				Name:   key,
+				Item:   types.NewItem(val, "string"),
+				Expiry: int64(binary.LittleEndian.Uint64(milliseconds)),
 			})
 
 		case 0xFB:
@@ -186,7 +189,7 @@ func ParseRDBFile(dir string, filename string) ([]RDBValue, error) {
 				return []RDBValue{}, fmt.Errorf("error reading value: %s", err.Error())
 			}
 
-			values = append(values, RDBValue{Name: key, Item: types.NewItem(val, "string", -1)})
+			values = append(values, RDBValue{Name: key, Item: types.NewItem(val, "string"), Expiry: -1})
 		default:
 			fmt.Println("Unknown database header", dbHeader)
 		}
@@ -206,8 +209,9 @@ func ReadRedisString(reader *bufio.Reader) (string, error) {
 		return strconv.Itoa(strLength), nil
 	}
 
+	// a zero length string is a valid, empty value
 	str := make([]byte, strLength)
-	_, err = reader.Read(str)
+	_, err = io.ReadFull(reader, str)
 	if err != nil {
 		return "", fmt.Errorf("error reading string: %s", err.Error())
 	}
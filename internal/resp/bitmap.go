@@ -0,0 +1,638 @@
+package resp
+
+import (
+	"math"
+	"math/bits"
+	"strconv"
+	"strings"
+
+	"nishojib/goredis/internal/parser"
+	"nishojib/goredis/internal/types"
+)
+
+const errBitOffset = "ERR bit offset is not an integer or out of range"
+
+// maxBitOffset is the last bit of the longest string a key can hold.
+const maxBitOffset = parser.MaxBulkLength*8 - 1
+
+// parseBitOffset parses the offset of a single bit.
+func parseBitOffset(arg []byte) (int64, bool) {
+	offset, err := parseInt(arg)
+	if err != nil || offset < 0 || offset > maxBitOffset {
+		return 0, false
+	}
+	return offset, true
+}
+
+// getBit returns the bit at offset, counting from the most significant bit
+// of the first byte. Bits past the end of buf read as 0.
+func getBit(buf []byte, offset int64) int64 {
+	if offset/8 >= int64(len(buf)) {
+		return 0
+	}
+	return int64(buf[offset/8]>>(7-offset%8)) & 1
+}
+
+// setBit sets the bit at offset to bit, which buf must be long enough for.
+func setBit(buf []byte, offset int64, bit int64) {
+	mask := byte(0x80) >> (offset % 8)
+	if bit == 1 {
+		buf[offset/8] |= mask
+	} else {
+		buf[offset/8] &^= mask
+	}
+}
+
+// growBits returns buf zero padded so that it holds the bit at offset.
+func growBits(buf []byte, offset int64) []byte {
+	if n := int(offset/8) + 1; n > len(buf) {
+		buf = append(buf, make([]byte, n-len(buf))...)
+	}
+	return buf
+}
+
+// bitRange resolves the start and end arguments of BITCOUNT and BITPOS for a
+// string of n bytes to the bits they cover, from the first to the last
+// inclusive. A BYTE range covers whole bytes. It returns false when the range
+// is empty.
+func bitRange(start int64, end int64, n int, bitUnit bool) (int64, int64, bool) {
+	total := int64(n)
+	if bitUnit {
+		total *= 8
+	}
+
+	if start < 0 && end < 0 && start > end {
+		return 0, 0, false
+	}
+
+	if start < 0 {
+		start = max(total+start, 0)
+	}
+	if end < 0 {
+		end = max(total+end, 0)
+	}
+	if end >= total {
+		end = total - 1
+	}
+
+	if start > end {
+		return 0, 0, false
+	}
+
+	if !bitUnit {
+		return start * 8, end*8 + 7, true
+	}
+	return start, end, true
+}
+
+// parseBitRange parses the optional start, end and unit arguments of
+// BITCOUNT and BITPOS. It replies with an error and returns false when they
+// are invalid.
+func parseBitRange(c *client, args [][]byte) (start int64, end int64, bitUnit bool, ok bool) {
+	end = -1
+
+	if len(args) > 3 {
+		c.w.WriteError(ErrSyntax.Error())
+		return 0, 0, false, false
+	}
+
+	var err error
+	if len(args) > 0 {
+		if start, err = parseInt(args[0]); err != nil {
+			c.w.WriteError(err.Error())
+			return 0, 0, false, false
+		}
+	}
+	if len(args) > 1 {
+		if end, err = parseInt(args[1]); err != nil {
+			c.w.WriteError(err.Error())
+			return 0, 0, false, false
+		}
+	}
+
+	if len(args) > 2 {
+		switch strings.ToUpper(string(args[2])) {
+		case "BIT":
+			bitUnit = true
+		case "BYTE":
+		default:
+			c.w.WriteError(ErrSyntax.Error())
+			return 0, 0, false, false
+		}
+	}
+
+	return start, end, bitUnit, true
+}
+
+func (rn *RESPNode) handleSetBit(c *client, args [][]byte) error {
+	key := string(args[0])
+
+	offset, ok := parseBitOffset(args[1])
+	if !ok {
+		c.w.WriteError(errBitOffset)
+		return nil
+	}
+
+	bit, err := parseInt(args[2])
+	if err != nil || (bit != 0 && bit != 1) {
+		c.w.WriteError("ERR bit is not an integer or out of range")
+		return nil
+	}
+
+	item, ok, err := rn.getString(key)
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	if !ok {
+		item = types.Item{Type: "string"}
+	}
+
+	// the bit is set in place, only growing the value copies it
+	item.Value = growBits(item.Value, offset)
+	old := getBit(item.Value, offset)
+	setBit(item.Value, offset, bit)
+
+	rn.cache.Store(key, item)
+	c.dirty++
+
+	c.w.WriteInteger(old)
+	return nil
+}
+
+func (rn *RESPNode) handleGetBit(c *client, args [][]byte) error {
+	offset, ok := parseBitOffset(args[1])
+	if !ok {
+		c.w.WriteError(errBitOffset)
+		return nil
+	}
+
+	item, _, err := rn.getString(string(args[0]))
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	c.w.WriteInteger(getBit(item.Value, offset))
+	return nil
+}
+
+func (rn *RESPNode) handleBitCount(c *client, args [][]byte) error {
+	if len(args) == 2 {
+		c.w.WriteError(ErrSyntax.Error())
+		return nil
+	}
+
+	start, end, bitUnit, ok := parseBitRange(c, args[1:])
+	if !ok {
+		return nil
+	}
+
+	item, _, err := rn.getString(string(args[0]))
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	buf := item.Value
+
+	first, last, ok := bitRange(start, end, len(buf), bitUnit)
+	if !ok {
+		c.w.WriteInteger(0)
+		return nil
+	}
+
+	count := 0
+	for i := first; i <= last; {
+		// whole bytes are counted at once
+		if i%8 == 0 && i+7 <= last {
+			count += bits.OnesCount8(buf[i/8])
+			i += 8
+			continue
+		}
+
+		count += int(getBit(buf, i))
+		i++
+	}
+
+	c.w.WriteInteger(int64(count))
+	return nil
+}
+
+func (rn *RESPNode) handleBitPos(c *client, args [][]byte) error {
+	bit, err := parseInt(args[1])
+	if err != nil || (bit != 0 && bit != 1) {
+		c.w.WriteError("ERR The bit argument must be 1 or 0.")
+		return nil
+	}
+
+	start, end, bitUnit, ok := parseBitRange(c, args[2:])
+	if !ok {
+		return nil
+	}
+	endGiven := len(args) > 3
+
+	item, exists, err := rn.getString(string(args[0]))
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	// a missing key reads as an endless run of zeros
+	if !exists {
+		if bit == 1 {
+			c.w.WriteInteger(-1)
+		} else {
+			c.w.WriteInteger(0)
+		}
+		return nil
+	}
+
+	buf := item.Value
+
+	first, last, ok := bitRange(start, end, len(buf), bitUnit)
+	if !ok {
+		c.w.WriteInteger(-1)
+		return nil
+	}
+
+	skip := byte(0)
+	if bit == 0 {
+		skip = 0xff
+	}
+
+	for i := first; i <= last; {
+		// whole bytes without the bit are skipped at once
+		if i%8 == 0 && i+7 <= last && buf[i/8] == skip {
+			i += 8
+			continue
+		}
+
+		if getBit(buf, i) == bit {
+			c.w.WriteInteger(i)
+			return nil
+		}
+		i++
+	}
+
+	// without an end the string reads as padded with zeros to the right
+	if bit == 0 && !endGiven {
+		c.w.WriteInteger(int64(len(buf)) * 8)
+		return nil
+	}
+
+	c.w.WriteInteger(-1)
+	return nil
+}
+
+func (rn *RESPNode) handleBitOp(c *client, args [][]byte) error {
+	op := strings.ToUpper(string(args[0]))
+	destination := string(args[1])
+
+	switch op {
+	case "AND", "OR", "XOR":
+	case "NOT":
+		if len(args) != 3 {
+			c.w.WriteError("ERR BITOP NOT must be called with a single source key.")
+			return nil
+		}
+	default:
+		c.w.WriteError(ErrSyntax.Error())
+		return nil
+	}
+
+	sources := make([][]byte, 0, len(args)-2)
+	length := 0
+	for _, key := range args[2:] {
+		item, _, err := rn.getString(string(key))
+		if err != nil {
+			c.w.WriteError(err.Error())
+			return nil
+		}
+
+		sources = append(sources, item.Value)
+		length = max(length, len(item.Value))
+	}
+
+	// shorter strings read as padded with zeros up to the longest one
+	result := make([]byte, length)
+	for i := range result {
+		var b byte
+		for j, src := range sources {
+			var s byte
+			if i < len(src) {
+				s = src[i]
+			}
+
+			switch {
+			case j == 0:
+				b = s
+			case op == "AND":
+				b &= s
+			case op == "OR":
+				b |= s
+			case op == "XOR":
+				b ^= s
+			}
+		}
+
+		if op == "NOT" {
+			b = ^b
+		}
+		result[i] = b
+	}
+
+	rn.deleteKey(destination)
+	if length > 0 {
+		rn.cache.Store(destination, types.Item{Value: result, Type: "string"})
+	}
+	c.dirty++
+
+	c.w.WriteInteger(int64(length))
+	return nil
+}
+
+// bitfieldOp is a GET, SET or INCRBY subcommand of BITFIELD on the integer of
+// the given width at offset, with the overflow mode in effect for it.
+type bitfieldOp struct {
+	kind     string
+	signed   bool
+	bits     int
+	offset   int64
+	value    int64
+	overflow string
+}
+
+// parseBitfieldType parses an encoding such as i8 or u16. Unsigned integers
+// are at most 63 bits wide so that they fit in an int64 reply.
+func parseBitfieldType(arg string) (signed bool, width int, ok bool) {
+	if len(arg) < 2 || (arg[0] != 'i' && arg[0] != 'u') {
+		return false, 0, false
+	}
+
+	signed = arg[0] == 'i'
+
+	n, err := strconv.Atoi(arg[1:])
+	if err != nil || n < 1 || (signed && n > 64) || (!signed && n > 63) {
+		return false, 0, false
+	}
+	return signed, n, true
+}
+
+// parseBitfieldOffset parses an offset in bits, or in multiples of the width
+// of the integer when it starts with #.
+func parseBitfieldOffset(arg string, width int) (int64, bool) {
+	multiply := strings.HasPrefix(arg, "#")
+	arg = strings.TrimPrefix(arg, "#")
+
+	offset, err := parseInt([]byte(arg))
+	if err != nil || offset < 0 {
+		return 0, false
+	}
+
+	if multiply {
+		if offset > maxBitOffset/int64(width) {
+			return 0, false
+		}
+		offset *= int64(width)
+	}
+
+	if offset+int64(width)-1 > maxBitOffset {
+		return 0, false
+	}
+	return offset, true
+}
+
+// parseBitfieldOps parses the subcommands of BITFIELD, or of BITFIELD_RO when
+// readonly is set. It replies with an error and returns false when they are
+// invalid.
+func parseBitfieldOps(c *client, args [][]byte, readonly bool) ([]bitfieldOp, bool) {
+	ops := []bitfieldOp{}
+	overflow := "WRAP"
+
+	for i := 0; i < len(args); {
+		kind := strings.ToUpper(string(args[i]))
+
+		if readonly && kind != "GET" {
+			c.w.WriteError("ERR BITFIELD_RO only supports the GET subcommand")
+			return nil, false
+		}
+
+		if kind == "OVERFLOW" && i+1 < len(args) {
+			overflow = strings.ToUpper(string(args[i+1]))
+			if overflow != "WRAP" && overflow != "SAT" && overflow != "FAIL" {
+				c.w.WriteError("ERR Invalid OVERFLOW type specified")
+				return nil, false
+			}
+			i += 2
+			continue
+		}
+
+		arity := 4
+		switch kind {
+		case "GET":
+			arity = 3
+		case "SET", "INCRBY":
+		default:
+			c.w.WriteError(ErrSyntax.Error())
+			return nil, false
+		}
+
+		if i+arity > len(args) {
+			c.w.WriteError(ErrSyntax.Error())
+			return nil, false
+		}
+
+		op := bitfieldOp{kind: kind, overflow: overflow}
+
+		var ok bool
+		op.signed, op.bits, ok = parseBitfieldType(string(args[i+1]))
+		if !ok {
+			c.w.WriteError(
+				"ERR Invalid bitfield type. Use something like i16 u8. " +
+					"Note that u64 is not supported but i64 is.",
+			)
+			return nil, false
+		}
+
+		if op.offset, ok = parseBitfieldOffset(string(args[i+2]), op.bits); !ok {
+			c.w.WriteError(errBitOffset)
+			return nil, false
+		}
+
+		if arity == 4 {
+			var err error
+			if op.value, err = parseInt(args[i+3]); err != nil {
+				c.w.WriteError(err.Error())
+				return nil, false
+			}
+		}
+
+		ops = append(ops, op)
+		i += arity
+	}
+
+	return ops, true
+}
+
+// getBitfield returns the integer of the given width at offset.
+func getBitfield(buf []byte, offset int64, width int, signed bool) int64 {
+	var value uint64
+	for i := range int64(width) {
+		value = value<<1 | uint64(getBit(buf, offset+i))
+	}
+
+	// sign extend negative integers
+	if signed && width < 64 && value&(1<<(width-1)) != 0 {
+		value |= math.MaxUint64 << width
+	}
+	return int64(value)
+}
+
+// setBitfield writes the low width bits of value at offset, which buf must be
+// long enough for.
+func setBitfield(buf []byte, offset int64, width int, value int64) {
+	for i := range width {
+		setBit(buf, offset+int64(i), int64(uint64(value)>>(width-1-i))&1)
+	}
+}
+
+// signedOverflow returns value plus incr for a signed integer of the given
+// width, handling overflows according to mode. It returns false when the
+// mode is FAIL and the result overflows.
+func signedOverflow(value int64, incr int64, width int, mode string) (int64, bool) {
+	hi := int64(math.MaxInt64)
+	if width < 64 {
+		hi = 1<<(width-1) - 1
+	}
+	lo := -hi - 1
+
+	var limit int64
+	switch {
+	case value > hi || (incr > 0 && value > hi-incr):
+		limit = hi
+	case value < lo || (incr < 0 && value < lo-incr):
+		limit = lo
+	default:
+		return value + incr, true
+	}
+
+	switch mode {
+	case "SAT":
+		return limit, true
+	case "FAIL":
+		return 0, false
+	}
+
+	result := uint64(value) + uint64(incr)
+	if width < 64 {
+		if result&(1<<(width-1)) != 0 {
+			result |= math.MaxUint64 << width
+		} else {
+			result &^= math.MaxUint64 << width
+		}
+	}
+	return int64(result), true
+}
+
+// unsignedOverflow is signedOverflow for unsigned integers, which are never
+// wider than 63 bits.
+func unsignedOverflow(value uint64, incr int64, width int, mode string) (int64, bool) {
+	hi := uint64(1)<<width - 1
+
+	var limit uint64
+	switch {
+	case value > hi || (incr > 0 && uint64(incr) > hi-value):
+		limit = hi
+	case incr < 0 && uint64(-incr) > value:
+		limit = 0
+	default:
+		return int64(value + uint64(incr)), true
+	}
+
+	switch mode {
+	case "SAT":
+		return int64(limit), true
+	case "FAIL":
+		return 0, false
+	}
+
+	return int64((value + uint64(incr)) & hi), true
+}
+
+func (rn *RESPNode) handleBitField(c *client, args [][]byte) error {
+	return rn.bitfield(c, args, false)
+}
+
+func (rn *RESPNode) handleBitFieldRO(c *client, args [][]byte) error {
+	return rn.bitfield(c, args, true)
+}
+
+func (rn *RESPNode) bitfield(c *client, args [][]byte, readonly bool) error {
+	key := string(args[0])
+
+	ops, ok := parseBitfieldOps(c, args[1:], readonly)
+	if !ok {
+		return nil
+	}
+
+	item, exists, err := rn.getString(key)
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	// fields are written in place, only growing the value copies it
+	buf := item.Value
+	changes := 0
+
+	c.w.WriteArray(len(ops))
+	for _, op := range ops {
+		old := getBitfield(buf, op.offset, op.bits, op.signed)
+
+		if op.kind == "GET" {
+			c.w.WriteInteger(old)
+			continue
+		}
+
+		value, incr := op.value, int64(0)
+		if op.kind == "INCRBY" {
+			value, incr = old, op.value
+		}
+
+		var result int64
+		if op.signed {
+			result, ok = signedOverflow(value, incr, op.bits, op.overflow)
+		} else {
+			result, ok = unsignedOverflow(uint64(value), incr, op.bits, op.overflow)
+		}
+
+		if !ok {
+			c.w.WriteNull()
+			continue
+		}
+
+		buf = growBits(buf, op.offset+int64(op.bits)-1)
+		setBitfield(buf, op.offset, op.bits, result)
+		changes++
+
+		if op.kind == "SET" {
+			c.w.WriteInteger(old)
+		} else {
+			c.w.WriteInteger(result)
+		}
+	}
+
+	if changes > 0 {
+		if !exists {
+			item = types.Item{Type: "string"}
+		}
+		item.Value = buf
+
+		rn.cache.Store(key, item)
+		c.dirty++
+	}
+
+	return nil
+}
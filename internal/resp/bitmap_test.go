@@ -0,0 +1,123 @@
+package resp
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSignedOverflow(t *testing.T) {
+	tests := []struct {
+		name  string
+		value int64
+		incr  int64
+		width int
+		wrap  int64
+		sat   int64
+		// fails is whether FAIL refuses the result
+		fails bool
+	}{
+		{"i1 in range", -1, 1, 1, 0, 0, false},
+		{"i1 over", 0, 1, 1, -1, 0, true},
+		{"i1 under", -1, -1, 1, 0, -1, true},
+		{"i8 in range", 100, 27, 8, 127, 127, false},
+		{"i8 over by one", 127, 1, 8, -128, 127, true},
+		{"i8 under by one", -128, -1, 8, 127, -128, true},
+		{"i8 over by a lot", 100, 1000, 8, 76, 127, true},
+		{"i8 set above range", 200, 0, 8, -56, 127, true},
+		{"i8 set below range", -200, 0, 8, 56, -128, true},
+		{"i63 over by one", 1<<62 - 1, 1, 63, -1 << 62, 1<<62 - 1, true},
+		{"i64 in range", math.MaxInt64 - 1, 1, 64, math.MaxInt64, math.MaxInt64, false},
+		{"i64 over by one", math.MaxInt64, 1, 64, math.MinInt64, math.MaxInt64, true},
+		{"i64 under by one", math.MinInt64, -1, 64, math.MaxInt64, math.MinInt64, true},
+		{"i64 under by the most", math.MinInt64, math.MinInt64, 64, 0, math.MinInt64, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, ok := signedOverflow(tt.value, tt.incr, tt.width, "WRAP"); !ok || got != tt.wrap {
+				t.Errorf("WRAP = %d, %t, want %d, true", got, ok, tt.wrap)
+			}
+
+			if got, ok := signedOverflow(tt.value, tt.incr, tt.width, "SAT"); !ok || got != tt.sat {
+				t.Errorf("SAT = %d, %t, want %d, true", got, ok, tt.sat)
+			}
+
+			got, ok := signedOverflow(tt.value, tt.incr, tt.width, "FAIL")
+			if ok == tt.fails || (ok && got != tt.wrap) {
+				t.Errorf("FAIL = %d, %t, want failure %t", got, ok, tt.fails)
+			}
+		})
+	}
+}
+
+func TestUnsignedOverflow(t *testing.T) {
+	tests := []struct {
+		name  string
+		value uint64
+		incr  int64
+		width int
+		wrap  int64
+		sat   int64
+		fails bool
+	}{
+		{"u1 in range", 0, 1, 1, 1, 1, false},
+		{"u1 over", 1, 1, 1, 0, 1, true},
+		{"u1 under", 0, -1, 1, 1, 0, true},
+		{"u8 in range", 200, 55, 8, 255, 255, false},
+		{"u8 over by one", 255, 1, 8, 0, 255, true},
+		{"u8 under by one", 0, -1, 8, 255, 0, true},
+		{"u8 over by a lot", 250, 1000, 8, 226, 255, true},
+		{"u8 set above range", 300, 0, 8, 44, 255, true},
+		{"u8 set negative", math.MaxUint64, 0, 8, 255, 255, true},
+		{"u63 in range", math.MaxInt64 - 1, 1, 63, math.MaxInt64, math.MaxInt64, false},
+		{"u63 over by one", math.MaxInt64, 1, 63, 0, math.MaxInt64, true},
+		{"u63 under by one", 0, -1, 63, math.MaxInt64, 0, true},
+		{"u63 under by the most", 0, math.MinInt64, 63, 0, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, ok := unsignedOverflow(tt.value, tt.incr, tt.width, "WRAP"); !ok || got != tt.wrap {
+				t.Errorf("WRAP = %d, %t, want %d, true", got, ok, tt.wrap)
+			}
+
+			if got, ok := unsignedOverflow(tt.value, tt.incr, tt.width, "SAT"); !ok || got != tt.sat {
+				t.Errorf("SAT = %d, %t, want %d, true", got, ok, tt.sat)
+			}
+
+			got, ok := unsignedOverflow(tt.value, tt.incr, tt.width, "FAIL")
+			if ok == tt.fails || (ok && got != tt.wrap) {
+				t.Errorf("FAIL = %d, %t, want failure %t", got, ok, tt.fails)
+			}
+		})
+	}
+}
+
+func TestBitfieldRoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		offset int64
+		width  int
+		signed bool
+		value  int64
+	}{
+		{"i1", 0, 1, true, -1},
+		{"u1", 7, 1, false, 1},
+		{"i8 across bytes", 5, 8, true, -128},
+		{"u8 across bytes", 5, 8, false, 255},
+		{"i64 min", 3, 64, true, math.MinInt64},
+		{"i64 max", 3, 64, true, math.MaxInt64},
+		{"u63 max", 1, 63, false, math.MaxInt64},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := growBits(nil, tt.offset+int64(tt.width)-1)
+			setBitfield(buf, tt.offset, tt.width, tt.value)
+
+			if got := getBitfield(buf, tt.offset, tt.width, tt.signed); got != tt.value {
+				t.Errorf("getBitfield() = %d, want %d", got, tt.value)
+			}
+		})
+	}
+}
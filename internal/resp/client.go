@@ -0,0 +1,89 @@
+package resp
+
+import (
+	"io"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"nishojib/goredis/internal/parser"
+)
+
+type client struct {
+	id     int64
+	conn   net.Conn
+	reader *parser.Reader
+	w      *parser.Writer
+	name   string
+	master bool
+
+	// dirty counts the changes made by the command being executed, write
+	// commands that changed nothing are not propagated to replicas
+	dirty int
+	// repl replaces the command propagated to replicas when it is set
+	repl [][]string
+	// cmd is the command being executed
+	cmd *command
+}
+
+func (rn *RESPNode) newClient(conn net.Conn, reader *parser.Reader) *client {
+	return &client{
+		id:     rn.nextClientID.Add(1),
+		conn:   conn,
+		reader: reader,
+		w:      parser.NewWriter(conn),
+	}
+}
+
+// newMasterClient wraps the connection to our master. Commands coming from a
+// master are applied without replying, so its replies are discarded.
+func (rn *RESPNode) newMasterClient(conn net.Conn, reader *parser.Reader) *client {
+	c := rn.newClient(conn, reader)
+	c.w = parser.NewWriter(io.Discard)
+	c.master = true
+	return c
+}
+
+// rewrite replaces what the current command propagates to replicas, for
+// commands that would not give the same result if a replica ran them as is.
+func (c *client) rewrite(commands ...[]string) {
+	c.repl = commands
+}
+
+// watchClose watches the connection for the client going away while a
+// command blocks, which nothing else would notice since nothing reads from it
+// meanwhile. onClose is called when the client goes away, and gone reports
+// whether it has. stop ends the watch, and must be called before the
+// connection is read again.
+func (c *client) watchClose(onClose func()) (gone func() bool, stop func()) {
+	var closed, stopping atomic.Bool
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		// pipelined commands leave the client connected, so the watch ends
+		// there
+		if err := c.reader.Wait(); err != nil && !stopping.Load() {
+			closed.Store(true)
+
+			// onClose may need locks the blocked command holds while it
+			// stops the watch
+			go onClose()
+		}
+	}()
+
+	stop = func() {
+		stopping.Store(true)
+		if closed.Load() {
+			return
+		}
+
+		// the deadline makes the pending read return so the watch ends
+		c.conn.SetReadDeadline(time.Now())
+		<-done
+		c.conn.SetReadDeadline(time.Time{})
+	}
+
+	return closed.Load, stop
+}
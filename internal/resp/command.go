@@ -0,0 +1,707 @@
+package resp
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+type commandFlag uint
+
+const (
+	flagWrite commandFlag = 1 << iota
+	flagReadonly
+	flagAdmin
+	flagBlocking
+	flagPubSub
+)
+
+var flagNames = []struct {
+	flag commandFlag
+	name string
+}{
+	{flagWrite, "write"},
+	{flagReadonly, "readonly"},
+	{flagAdmin, "admin"},
+	{flagBlocking, "blocking"},
+	{flagPubSub, "pubsub"},
+}
+
+// command describes a command the server understands. Arity follows the
+// Redis convention: it counts the command name, a positive arity is an exact
+// number of arguments and a negative one is a minimum. Keys live between
+// firstKey and lastKey, every keyStep arguments, and a negative lastKey
+// counts from the end. Group and summary are what COMMAND DOCS reports.
+type command struct {
+	name     string
+	handler  func(rn *RESPNode, c *client, args [][]byte) error
+	arity    int
+	flags    commandFlag
+	firstKey int
+	lastKey  int
+	keyStep  int
+	group    string
+	summary  string
+}
+
+func (cmd *command) checkArity(args [][]byte) bool {
+	n := len(args) + 1
+	if cmd.arity < 0 {
+		return n >= -cmd.arity
+	}
+	return n == cmd.arity
+}
+
+// keys returns the positions of the keys in args, which includes the command
+// name at position 0.
+func (cmd *command) keys(args [][]byte) []int {
+	if cmd.firstKey == 0 {
+		if find, ok := movableKeys[cmd.name]; ok {
+			return find(args)
+		}
+		return nil
+	}
+
+	last := cmd.lastKey
+	if last < 0 {
+		last = len(args) + last
+	}
+
+	positions := []int{}
+	for i := cmd.firstKey; i <= last && i < len(args); i += cmd.keyStep {
+		positions = append(positions, i)
+	}
+	return positions
+}
+
+// movableKeys finds the keys of the commands whose key positions depend on
+// their other arguments. Such commands have a firstKey of 0.
+var movableKeys = map[string]func(args [][]byte) []int{
+	"xread":      streamsKeys,
+	"xreadgroup": streamsKeys,
+	"sintercard": numKeysKeys,
+}
+
+// streamsKeys returns the keys of a command ending in STREAMS key... id...
+func streamsKeys(args [][]byte) []int {
+	for i, arg := range args {
+		if !strings.EqualFold(string(arg), "streams") {
+			continue
+		}
+
+		positions := []int{}
+		for pos := i + 1; pos < i+1+(len(args)-i-1)/2; pos++ {
+			positions = append(positions, pos)
+		}
+		return positions
+	}
+	return nil
+}
+
+// numKeysKeys returns the keys of a command whose first argument is the
+// number of keys that follow it.
+func numKeysKeys(args [][]byte) []int {
+	if len(args) < 2 {
+		return nil
+	}
+
+	n, err := parseInt(args[1])
+	if err != nil || n <= 0 || n > int64(len(args)-2) {
+		return nil
+	}
+
+	positions := []int{}
+	for pos := 2; pos < 2+int(n); pos++ {
+		positions = append(positions, pos)
+	}
+	return positions
+}
+
+func (cmd *command) flagNames() []string {
+	names := []string{}
+	for _, f := range flagNames {
+		if cmd.flags&f.flag != 0 {
+			names = append(names, f.name)
+		}
+	}
+	if _, ok := movableKeys[cmd.name]; ok {
+		names = append(names, "movablekeys")
+	}
+	return names
+}
+
+// categories derives the ACL categories of the command from its flags and
+// group.
+func (cmd *command) categories() []string {
+	categories := []string{}
+
+	if cmd.flags&flagWrite != 0 {
+		categories = append(categories, "@write")
+	}
+	if cmd.flags&flagReadonly != 0 {
+		categories = append(categories, "@read")
+	}
+	if cmd.flags&flagAdmin != 0 {
+		categories = append(categories, "@admin", "@dangerous")
+	}
+	if cmd.flags&flagBlocking != 0 {
+		categories = append(categories, "@blocking")
+	}
+	if cmd.flags&flagPubSub != 0 {
+		categories = append(categories, "@pubsub")
+	}
+
+	switch cmd.group {
+	case "generic":
+		categories = append(categories, "@keyspace")
+	case "sorted-set":
+		categories = append(categories, "@sortedset")
+	case "server":
+	default:
+		categories = append(categories, "@"+cmd.group)
+	}
+
+	return categories
+}
+
+// commandTable is filled in init because COMMAND and friends look it up from
+// their own handlers.
+var commandTable map[string]*command
+
+func init() {
+	commands := []*command{
+		{"ping", (*RESPNode).handlePing, -1, 0, 0, 0, 0,
+			"connection", "Returns the server's liveliness response."},
+		{"echo", (*RESPNode).handleEcho, 2, 0, 0, 0, 0,
+			"connection", "Returns the given string."},
+		{"hello", (*RESPNode).handleHello, -1, 0, 0, 0, 0,
+			"connection", "Handshakes with the Redis server."},
+		{"info", (*RESPNode).handleInfo, -1, 0, 0, 0, 0,
+			"server", "Returns information and statistics about the server."},
+		{"config", (*RESPNode).handleConfig, -2, flagAdmin, 0, 0, 0,
+			"server", "Returns the effective values of configuration parameters."},
+		{"command", (*RESPNode).handleCommand, -1, 0, 0, 0, 0,
+			"server", "Returns detailed information about all commands."},
+		{"replconf", (*RESPNode).handleReplconf, -1, flagAdmin, 0, 0, 0,
+			"server", "An internal command for configuring the replication stream."},
+		{"psync", (*RESPNode).handlePsync, -3, flagAdmin, 0, 0, 0,
+			"server", "An internal command used in replication."},
+		{"wait", (*RESPNode).handleWait, 3, flagBlocking, 0, 0, 0,
+			"generic", "Blocks until the writes sent by the connection are replicated."},
+		{"keys", (*RESPNode).handleKeys, 2, flagReadonly, 0, 0, 0,
+			"generic", "Returns all key names that match a pattern."},
+		{"type", (*RESPNode).handleType, 2, flagReadonly, 1, 1, 1,
+			"generic", "Determines the type of value stored at a key."},
+		{"del", (*RESPNode).handleDel, -2, flagWrite, 1, -1, 1,
+			"generic", "Deletes one or more keys."},
+		{"unlink", (*RESPNode).handleUnlink, -2, flagWrite, 1, -1, 1,
+			"generic", "Asynchronously deletes one or more keys."},
+		{"exists", (*RESPNode).handleExists, -2, flagReadonly, 1, -1, 1,
+			"generic", "Determines whether one or more keys exist."},
+		{"touch", (*RESPNode).handleTouch, -2, flagReadonly, 1, -1, 1,
+			"generic", "Returns the number of existing keys out of those specified after updating the time they were last accessed."},
+		{"rename", (*RESPNode).handleRename, 3, flagWrite, 1, 2, 1,
+			"generic", "Renames a key and overwrites the destination."},
+		{"renamenx", (*RESPNode).handleRenameNX, 3, flagWrite, 1, 2, 1,
+			"generic", "Renames a key only when the target key name doesn't exist."},
+		{"copy", (*RESPNode).handleCopy, -3, flagWrite, 1, 2, 1,
+			"generic", "Copies the value of a key to a new key."},
+		{"randomkey", (*RESPNode).handleRandomKey, 1, flagReadonly, 0, 0, 0,
+			"generic", "Returns a random key name from the database."},
+		{"dbsize", (*RESPNode).handleDBSize, 1, flagReadonly, 0, 0, 0,
+			"server", "Returns the number of keys in the database."},
+		{"expire", (*RESPNode).handleExpire, -3, flagWrite, 1, 1, 1,
+			"generic", "Sets the expiration time of a key in seconds."},
+		{"pexpire", (*RESPNode).handlePExpire, -3, flagWrite, 1, 1, 1,
+			"generic", "Sets the expiration time of a key in milliseconds."},
+		{"expireat", (*RESPNode).handleExpireAt, -3, flagWrite, 1, 1, 1,
+			"generic", "Sets the expiration time of a key to a Unix timestamp."},
+		{"pexpireat", (*RESPNode).handlePExpireAt, -3, flagWrite, 1, 1, 1,
+			"generic", "Sets the expiration time of a key to a Unix milliseconds timestamp."},
+		{"ttl", (*RESPNode).handleTTL, 2, flagReadonly, 1, 1, 1,
+			"generic", "Returns the expiration time in seconds of a key."},
+		{"pttl", (*RESPNode).handlePTTL, 2, flagReadonly, 1, 1, 1,
+			"generic", "Returns the expiration time in milliseconds of a key."},
+		{"expiretime", (*RESPNode).handleExpireTime, 2, flagReadonly, 1, 1, 1,
+			"generic", "Returns the expiration time of a key as a Unix timestamp."},
+		{"pexpiretime", (*RESPNode).handlePExpireTime, 2, flagReadonly, 1, 1, 1,
+			"generic", "Returns the expiration time of a key as a Unix milliseconds timestamp."},
+		{"persist", (*RESPNode).handlePersist, 2, flagWrite, 1, 1, 1,
+			"generic", "Removes the expiration time of a key."},
+		{"set", (*RESPNode).handleSet, -3, flagWrite, 1, 1, 1,
+			"string", "Sets the string value of a key."},
+		{"get", (*RESPNode).handleGet, 2, flagReadonly, 1, 1, 1,
+			"string", "Returns the string value of a key."},
+		{"incr", (*RESPNode).handleIncr, 2, flagWrite, 1, 1, 1,
+			"string", "Increments the integer value of a key by one."},
+		{"decr", (*RESPNode).handleDecr, 2, flagWrite, 1, 1, 1,
+			"string", "Decrements the integer value of a key by one."},
+		{"incrby", (*RESPNode).handleIncrBy, 3, flagWrite, 1, 1, 1,
+			"string", "Increments the integer value of a key by a number."},
+		{"decrby", (*RESPNode).handleDecrBy, 3, flagWrite, 1, 1, 1,
+			"string", "Decrements a number from the integer value of a key."},
+		{"incrbyfloat", (*RESPNode).handleIncrByFloat, 3, flagWrite, 1, 1, 1,
+			"string", "Increment the floating point value of a key by a number."},
+		{"append", (*RESPNode).handleAppend, 3, flagWrite, 1, 1, 1,
+			"string", "Appends a string to the value of a key."},
+		{"strlen", (*RESPNode).handleStrlen, 2, flagReadonly, 1, 1, 1,
+			"string", "Returns the length of a string value."},
+		{"getrange", (*RESPNode).handleGetRange, 4, flagReadonly, 1, 1, 1,
+			"string", "Returns a substring of the string stored at a key."},
+		{"setrange", (*RESPNode).handleSetRange, 4, flagWrite, 1, 1, 1,
+			"string", "Overwrites a part of a string value with another by an offset."},
+		{"mget", (*RESPNode).handleMGet, -2, flagReadonly, 1, -1, 1,
+			"string", "Atomically returns the string values of one or more keys."},
+		{"mset", (*RESPNode).handleMSet, -3, flagWrite, 1, -1, 2,
+			"string", "Atomically creates or modifies the string values of one or more keys."},
+		{"msetnx", (*RESPNode).handleMSetNX, -3, flagWrite, 1, -1, 2,
+			"string", "Atomically modifies the string values of one or more keys only when all keys don't exist."},
+		{"setnx", (*RESPNode).handleSetNX, 3, flagWrite, 1, 1, 1,
+			"string", "Set the string value of a key only when the key doesn't exist."},
+		{"setex", (*RESPNode).handleSetEX, 4, flagWrite, 1, 1, 1,
+			"string", "Sets the string value and expiration time of a key."},
+		{"psetex", (*RESPNode).handlePSetEX, 4, flagWrite, 1, 1, 1,
+			"string", "Sets both string value and expiration time in milliseconds of a key."},
+		{"getdel", (*RESPNode).handleGetDel, 2, flagWrite, 1, 1, 1,
+			"string", "Returns the string value of a key after deleting the key."},
+		{"getex", (*RESPNode).handleGetEX, -2, flagWrite, 1, 1, 1,
+			"string", "Returns the string value of a key after setting its expiration time."},
+		{"setbit", (*RESPNode).handleSetBit, 4, flagWrite, 1, 1, 1,
+			"bitmap", "Sets or clears the bit at offset of the string value. Creates the key if it doesn't exist."},
+		{"getbit", (*RESPNode).handleGetBit, 3, flagReadonly, 1, 1, 1,
+			"bitmap", "Returns a bit value by offset."},
+		{"bitcount", (*RESPNode).handleBitCount, -2, flagReadonly, 1, 1, 1,
+			"bitmap", "Counts the number of set bits (population counting) in a string."},
+		{"bitpos", (*RESPNode).handleBitPos, -3, flagReadonly, 1, 1, 1,
+			"bitmap", "Finds the first set (1) or clear (0) bit in a string."},
+		{"bitop", (*RESPNode).handleBitOp, -4, flagWrite, 2, -1, 1,
+			"bitmap", "Performs bitwise operations on multiple strings, and stores the result."},
+		{"bitfield", (*RESPNode).handleBitField, -2, flagWrite, 1, 1, 1,
+			"bitmap", "Performs arbitrary bitfield integer operations on strings."},
+		{"bitfield_ro", (*RESPNode).handleBitFieldRO, -2, flagReadonly, 1, 1, 1,
+			"bitmap", "Performs arbitrary read-only bitfield integer operations on strings."},
+		{"pfadd", (*RESPNode).handlePFAdd, -2, flagWrite, 1, 1, 1,
+			"hyperloglog", "Adds elements to a HyperLogLog key. Creates the key if it doesn't exist."},
+		{"pfcount", (*RESPNode).handlePFCount, -2, flagReadonly, 1, -1, 1,
+			"hyperloglog", "Returns the approximated cardinality of the set(s) observed by the HyperLogLog key(s)."},
+		{"pfmerge", (*RESPNode).handlePFMerge, -2, flagWrite, 1, -1, 1,
+			"hyperloglog", "Merges one or more HyperLogLog values into a single key."},
+		{"lpush", (*RESPNode).handleLPush, -3, flagWrite, 1, 1, 1,
+			"list", "Prepends one or more elements to a list. Creates the key if it doesn't exist."},
+		{"rpush", (*RESPNode).handleRPush, -3, flagWrite, 1, 1, 1,
+			"list", "Appends one or more elements to a list. Creates the key if it doesn't exist."},
+		{"lpop", (*RESPNode).handleLPop, -2, flagWrite, 1, 1, 1,
+			"list", "Returns the first elements in a list after removing it. Deletes the list if the last element was popped."},
+		{"rpop", (*RESPNode).handleRPop, -2, flagWrite, 1, 1, 1,
+			"list", "Returns and removes the last elements of a list. Deletes the list if the last element was popped."},
+		{"lrange", (*RESPNode).handleLRange, 4, flagReadonly, 1, 1, 1,
+			"list", "Returns a range of elements from a list."},
+		{"llen", (*RESPNode).handleLLen, 2, flagReadonly, 1, 1, 1,
+			"list", "Returns the length of a list."},
+		{"lindex", (*RESPNode).handleLIndex, 3, flagReadonly, 1, 1, 1,
+			"list", "Returns an element from a list by its index."},
+		{"lset", (*RESPNode).handleLSet, 4, flagWrite, 1, 1, 1,
+			"list", "Sets the value of an element in a list by its index."},
+		{"lrem", (*RESPNode).handleLRem, 4, flagWrite, 1, 1, 1,
+			"list", "Removes elements from a list. Deletes the list if the last element was removed."},
+		{"ltrim", (*RESPNode).handleLTrim, 4, flagWrite, 1, 1, 1,
+			"list", "Removes elements from both ends a list. Deletes the list if all elements were trimmed."},
+		{"linsert", (*RESPNode).handleLInsert, 5, flagWrite, 1, 1, 1,
+			"list", "Inserts an element before or after another element in a list."},
+		{"lpos", (*RESPNode).handleLPos, -3, flagReadonly, 1, 1, 1,
+			"list", "Returns the index of matching elements in a list."},
+		{"lmove", (*RESPNode).handleLMove, 5, flagWrite, 1, 2, 1,
+			"list", "Returns an element after popping it from one list and pushing it to another. Deletes the list if the last element was moved."},
+		{"hset", (*RESPNode).handleHSet, -4, flagWrite, 1, 1, 1,
+			"hash", "Creates or modifies the value of a field in a hash."},
+		{"hsetnx", (*RESPNode).handleHSetNX, 4, flagWrite, 1, 1, 1,
+			"hash", "Sets the value of a field in a hash only when the field doesn't exist."},
+		{"hget", (*RESPNode).handleHGet, 3, flagReadonly, 1, 1, 1,
+			"hash", "Returns the value of a field in a hash."},
+		{"hmget", (*RESPNode).handleHMGet, -3, flagReadonly, 1, 1, 1,
+			"hash", "Returns the values of all fields in a hash."},
+		{"hdel", (*RESPNode).handleHDel, -3, flagWrite, 1, 1, 1,
+			"hash", "Deletes one or more fields and their values from a hash. Deletes the hash if no fields remain."},
+		{"hgetall", (*RESPNode).handleHGetAll, 2, flagReadonly, 1, 1, 1,
+			"hash", "Returns all fields and values in a hash."},
+		{"hkeys", (*RESPNode).handleHKeys, 2, flagReadonly, 1, 1, 1,
+			"hash", "Returns all fields in a hash."},
+		{"hvals", (*RESPNode).handleHVals, 2, flagReadonly, 1, 1, 1,
+			"hash", "Returns all values in a hash."},
+		{"hlen", (*RESPNode).handleHLen, 2, flagReadonly, 1, 1, 1,
+			"hash", "Returns the number of fields in a hash."},
+		{"hexists", (*RESPNode).handleHExists, 3, flagReadonly, 1, 1, 1,
+			"hash", "Determines whether a field exists in a hash."},
+		{"hstrlen", (*RESPNode).handleHStrlen, 3, flagReadonly, 1, 1, 1,
+			"hash", "Returns the length of the value of a field."},
+		{"hincrby", (*RESPNode).handleHIncrBy, 4, flagWrite, 1, 1, 1,
+			"hash", "Increments the integer value of a field in a hash by a number. Uses 0 as initial value if the field doesn't exist."},
+		{"hincrbyfloat", (*RESPNode).handleHIncrByFloat, 4, flagWrite, 1, 1, 1,
+			"hash", "Increments the floating point value of a field by a number. Uses 0 as initial value if the field doesn't exist."},
+		{"hrandfield", (*RESPNode).handleHRandField, -2, flagReadonly, 1, 1, 1,
+			"hash", "Returns one or more random fields from a hash."},
+		{"sadd", (*RESPNode).handleSAdd, -3, flagWrite, 1, 1, 1,
+			"set", "Adds one or more members to a set. Creates the key if it doesn't exist."},
+		{"srem", (*RESPNode).handleSRem, -3, flagWrite, 1, 1, 1,
+			"set", "Removes one or more members from a set. Deletes the set if the last member was removed."},
+		{"smembers", (*RESPNode).handleSMembers, 2, flagReadonly, 1, 1, 1,
+			"set", "Returns all members of a set."},
+		{"sismember", (*RESPNode).handleSIsMember, 3, flagReadonly, 1, 1, 1,
+			"set", "Determines whether a member belongs to a set."},
+		{"smismember", (*RESPNode).handleSMIsMember, -3, flagReadonly, 1, 1, 1,
+			"set", "Determines whether multiple members belong to a set."},
+		{"scard", (*RESPNode).handleSCard, 2, flagReadonly, 1, 1, 1,
+			"set", "Returns the number of members in a set."},
+		{"spop", (*RESPNode).handleSPop, -2, flagWrite, 1, 1, 1,
+			"set", "Returns one or more random members from a set after removing them. Deletes the set if the last member was popped."},
+		{"srandmember", (*RESPNode).handleSRandMember, -2, flagReadonly, 1, 1, 1,
+			"set", "Get one or multiple random members from a set"},
+		{"smove", (*RESPNode).handleSMove, 4, flagWrite, 1, 2, 1,
+			"set", "Moves a member from one set to another."},
+		{"sinter", (*RESPNode).handleSInter, -2, flagReadonly, 1, -1, 1,
+			"set", "Returns the intersect of multiple sets."},
+		{"sunion", (*RESPNode).handleSUnion, -2, flagReadonly, 1, -1, 1,
+			"set", "Returns the union of multiple sets."},
+		{"sdiff", (*RESPNode).handleSDiff, -2, flagReadonly, 1, -1, 1,
+			"set", "Returns the difference of multiple sets."},
+		{"sinterstore", (*RESPNode).handleSInterStore, -3, flagWrite, 1, -1, 1,
+			"set", "Stores the intersect of multiple sets in a key."},
+		{"sunionstore", (*RESPNode).handleSUnionStore, -3, flagWrite, 1, -1, 1,
+			"set", "Stores the union of multiple sets in a key."},
+		{"sdiffstore", (*RESPNode).handleSDiffStore, -3, flagWrite, 1, -1, 1,
+			"set", "Stores the difference of multiple sets in a key."},
+		{"sintercard", (*RESPNode).handleSInterCard, -3, flagReadonly, 0, 0, 0,
+			"set", "Returns the number of members of the intersect of multiple sets."},
+		{"zadd", (*RESPNode).handleZAdd, -4, flagWrite, 1, 1, 1,
+			"sorted-set", "Adds one or more members to a sorted set, or updates their scores. Creates the key if it doesn't exist."},
+		{"zincrby", (*RESPNode).handleZIncrBy, 4, flagWrite, 1, 1, 1,
+			"sorted-set", "Increments the score of a member in a sorted set."},
+		{"zcard", (*RESPNode).handleZCard, 2, flagReadonly, 1, 1, 1,
+			"sorted-set", "Returns the number of members in a sorted set."},
+		{"zscore", (*RESPNode).handleZScore, 3, flagReadonly, 1, 1, 1,
+			"sorted-set", "Returns the score of a member in a sorted set."},
+		{"zmscore", (*RESPNode).handleZMScore, -3, flagReadonly, 1, 1, 1,
+			"sorted-set", "Returns the score of one or more members in a sorted set."},
+		{"zrank", (*RESPNode).handleZRank, -3, flagReadonly, 1, 1, 1,
+			"sorted-set", "Returns the index of a member in a sorted set ordered by ascending scores."},
+		{"zrevrank", (*RESPNode).handleZRevRank, -3, flagReadonly, 1, 1, 1,
+			"sorted-set", "Returns the index of a member in a sorted set ordered by descending scores."},
+		{"zcount", (*RESPNode).handleZCount, 4, flagReadonly, 1, 1, 1,
+			"sorted-set", "Returns the count of members in a sorted set that have scores within a range."},
+		{"zlexcount", (*RESPNode).handleZLexCount, 4, flagReadonly, 1, 1, 1,
+			"sorted-set", "Returns the number of members in a sorted set within a lexicographical range."},
+		{"zrange", (*RESPNode).handleZRange, -4, flagReadonly, 1, 1, 1,
+			"sorted-set", "Returns members in a sorted set within a range of indexes."},
+		{"zrangestore", (*RESPNode).handleZRangeStore, -5, flagWrite, 1, 2, 1,
+			"sorted-set", "Stores a range of members from sorted set in a key."},
+		{"zrem", (*RESPNode).handleZRem, -3, flagWrite, 1, 1, 1,
+			"sorted-set", "Removes one or more members from a sorted set. Deletes the sorted set if all members were removed."},
+		{"zremrangebyrank", (*RESPNode).handleZRemRangeByRank, 4, flagWrite, 1, 1, 1,
+			"sorted-set", "Removes members in a sorted set within a range of indexes. Deletes the sorted set if all members were removed."},
+		{"zremrangebyscore", (*RESPNode).handleZRemRangeByScore, 4, flagWrite, 1, 1, 1,
+			"sorted-set", "Removes members in a sorted set within a range of scores. Deletes the sorted set if all members were removed."},
+		{"zremrangebylex", (*RESPNode).handleZRemRangeByLex, 4, flagWrite, 1, 1, 1,
+			"sorted-set", "Removes members in a sorted set within a lexicographical range. Deletes the sorted set if all members were removed."},
+		{"zpopmin", (*RESPNode).handleZPopMin, -2, flagWrite, 1, 1, 1,
+			"sorted-set", "Returns the lowest-scoring members from a sorted set after removing them. Deletes the sorted set if the last member was popped."},
+		{"zpopmax", (*RESPNode).handleZPopMax, -2, flagWrite, 1, 1, 1,
+			"sorted-set", "Returns the highest-scoring members from a sorted set after removing them. Deletes the sorted set if the last member was popped."},
+		{"xadd", (*RESPNode).handleXAdd, -5, flagWrite, 1, 1, 1,
+			"stream", "Appends a new message to a stream."},
+		{"geoadd", (*RESPNode).handleGeoAdd, -5, flagWrite, 1, 1, 1,
+			"geo", "Adds one or more members to a geospatial index. The key is created if it doesn't exist."},
+		{"geodist", (*RESPNode).handleGeoDist, -4, flagReadonly, 1, 1, 1,
+			"geo", "Returns the distance between two members of a geospatial index."},
+		{"geohash", (*RESPNode).handleGeoHash, -2, flagReadonly, 1, 1, 1,
+			"geo", "Returns members from a geospatial index as geohash strings."},
+		{"geopos", (*RESPNode).handleGeoPos, -2, flagReadonly, 1, 1, 1,
+			"geo", "Returns the longitude and latitude of members from a geospatial index."},
+		{"geosearch", (*RESPNode).handleGeoSearch, -7, flagReadonly, 1, 1, 1,
+			"geo", "Queries a geospatial index for members inside an area of a box or a circle."},
+		{"geosearchstore", (*RESPNode).handleGeoSearchStore, -8, flagWrite, 1, 2, 1,
+			"geo", "Queries a geospatial index for members inside an area of a box or a circle, optionally stores the result."},
+		{"xlen", (*RESPNode).handleXLen, 2, flagReadonly, 1, 1, 1,
+			"stream", "Return the number of messages in a stream."},
+		{"xdel", (*RESPNode).handleXDel, -3, flagWrite, 1, 1, 1,
+			"stream", "Returns the number of messages after removing them from a stream."},
+		{"xtrim", (*RESPNode).handleXTrim, -4, flagWrite, 1, 1, 1,
+			"stream", "Deletes messages from the beginning of a stream."},
+		{"xgroup", (*RESPNode).handleXGroup, -2, flagWrite, 2, 2, 1,
+			"stream", "A container for consumer groups commands."},
+		{"xreadgroup", (*RESPNode).handleXReadGroup, -7, flagWrite | flagBlocking, 0, 0, 0,
+			"stream", "Returns new or historical messages from a stream for a consumer in a group. Blocks until a message is available otherwise."},
+		{"xack", (*RESPNode).handleXAck, -4, flagWrite, 1, 1, 1,
+			"stream", "Returns the number of messages that were successfully acknowledged by the consumer group member of a stream."},
+		{"xpending", (*RESPNode).handleXPending, -3, flagReadonly, 1, 1, 1,
+			"stream", "Returns the information and entries from a stream consumer group's pending entries list."},
+		{"xclaim", (*RESPNode).handleXClaim, -6, flagWrite, 1, 1, 1,
+			"stream", "Changes, or acquires, ownership of a message in a consumer group, as if the message was delivered a consumer group member."},
+		{"xautoclaim", (*RESPNode).handleXAutoClaim, -6, flagWrite, 1, 1, 1,
+			"stream", "Changes, or acquires, ownership of messages in a consumer group, as if the messages were delivered to as consumer group member."},
+		{"xinfo", (*RESPNode).handleXInfo, -2, flagReadonly, 2, 2, 1,
+			"stream", "A container for stream introspection commands."},
+		{"xrange", (*RESPNode).handleXRange, -4, flagReadonly, 1, 1, 1,
+			"stream", "Returns the messages from a stream within a range of IDs."},
+		{"xrevrange", (*RESPNode).handleXRevRange, -4, flagReadonly, 1, 1, 1,
+			"stream", "Returns the messages from a stream within a range of IDs in reverse order."},
+		{"xread", (*RESPNode).handleXRead, -4, flagReadonly | flagBlocking, 0, 0, 0,
+			"stream", "Returns messages from multiple streams with IDs greater than the ones requested. Blocks until a message is available otherwise."},
+	}
+
+	commandTable = make(map[string]*command, len(commands))
+	for _, cmd := range commands {
+		commandTable[cmd.name] = cmd
+	}
+}
+
+// sortedCommands returns the command table ordered by name, so replies
+// listing every command are stable.
+func sortedCommands() []*command {
+	commands := make([]*command, 0, len(commandTable))
+	for _, cmd := range commandTable {
+		commands = append(commands, cmd)
+	}
+
+	slices.SortFunc(commands, func(a, b *command) int {
+		return strings.Compare(a.name, b.name)
+	})
+	return commands
+}
+
+func (rn *RESPNode) handleCommand(c *client, args [][]byte) error {
+	if len(args) == 0 {
+		commands := sortedCommands()
+
+		c.w.WriteArray(len(commands))
+		for _, cmd := range commands {
+			writeCommandInfo(c, cmd)
+		}
+		return nil
+	}
+
+	switch strings.ToLower(string(args[0])) {
+	case "count":
+		if len(args) != 1 {
+			break
+		}
+
+		c.w.WriteInteger(int64(len(commandTable)))
+		return nil
+
+	case "info":
+		names := args[1:]
+		if len(names) == 0 {
+			return rn.handleCommand(c, nil)
+		}
+
+		c.w.WriteArray(len(names))
+		for _, name := range names {
+			cmd, ok := commandTable[strings.ToLower(string(name))]
+			if !ok {
+				c.w.WriteNullArray()
+				continue
+			}
+			writeCommandInfo(c, cmd)
+		}
+		return nil
+
+	case "docs":
+		commands := []*command{}
+		if len(args) == 1 {
+			commands = sortedCommands()
+		}
+		for _, name := range args[1:] {
+			if cmd, ok := commandTable[strings.ToLower(string(name))]; ok {
+				commands = append(commands, cmd)
+			}
+		}
+
+		c.w.WriteMap(len(commands))
+		for _, cmd := range commands {
+			c.w.WriteBulkString(cmd.name)
+			c.w.WriteMap(2)
+			c.w.WriteBulkString("summary")
+			c.w.WriteBulkString(cmd.summary)
+			c.w.WriteBulkString("group")
+			c.w.WriteBulkString(cmd.group)
+		}
+		return nil
+
+	case "list":
+		return rn.handleCommandList(c, args[1:])
+
+	case "getkeys", "getkeysandflags":
+		if len(args) < 2 {
+			break
+		}
+
+		cmd, ok := commandTable[strings.ToLower(string(args[1]))]
+		if !ok {
+			c.w.WriteError("ERR Invalid command specified")
+			return nil
+		}
+
+		if !cmd.checkArity(args[2:]) {
+			c.w.WriteError("ERR Invalid number of arguments specified for command")
+			return nil
+		}
+
+		positions := cmd.keys(args[1:])
+		if len(positions) == 0 {
+			c.w.WriteError("ERR The command has no key arguments")
+			return nil
+		}
+
+		withFlags := strings.EqualFold(string(args[0]), "getkeysandflags")
+
+		c.w.WriteArray(len(positions))
+		for _, pos := range positions {
+			if !withFlags {
+				c.w.WriteBulkString(string(args[pos+1]))
+				continue
+			}
+
+			c.w.WriteArray(2)
+			c.w.WriteBulkString(string(args[pos+1]))
+			c.w.WriteSet(len(keySpecFlags(cmd)))
+			for _, flag := range keySpecFlags(cmd) {
+				c.w.WriteSimpleString(flag)
+			}
+		}
+		return nil
+
+	case "help":
+		c.w.WriteBulkStrings([]string{
+			"COMMAND <subcommand> [<arg> [value] [opt] ...]. Subcommands are:",
+			"(no subcommand)",
+			"    Return details about all Redis commands.",
+			"COUNT",
+			"    Return the total number of commands in this Redis server.",
+			"LIST [FILTERBY (MODULE <module-name>|ACLCAT <category>|PATTERN <pattern>)]",
+			"    Return a list of all commands in this Redis server.",
+			"INFO [<command-name> ...]",
+			"    Return details about multiple Redis commands.",
+			"DOCS [<command-name> ...]",
+			"    Return documentation details about multiple Redis commands.",
+			"GETKEYS <full-command>",
+			"    Return the keys from a full Redis command.",
+			"GETKEYSANDFLAGS <full-command>",
+			"    Return the keys and the access flags from a full Redis command.",
+			"HELP",
+			"    Print this help.",
+		})
+		return nil
+	}
+
+	c.w.WriteError(fmt.Sprintf(
+		"ERR unknown subcommand or wrong number of arguments for '%s'. Try COMMAND HELP.",
+		args[0],
+	))
+	return nil
+}
+
+func (rn *RESPNode) handleCommandList(c *client, args [][]byte) error {
+	commands := sortedCommands()
+
+	if len(args) > 0 {
+		if len(args) != 3 || !strings.EqualFold(string(args[0]), "filterby") {
+			c.w.WriteError("ERR syntax error")
+			return nil
+		}
+
+		filter, value := strings.ToLower(string(args[1])), string(args[2])
+
+		commands = slices.DeleteFunc(commands, func(cmd *command) bool {
+			switch filter {
+			case "pattern":
+				return !stringMatch(value, cmd.name, true)
+			case "aclcat":
+				return !slices.Contains(cmd.categories(), "@"+strings.ToLower(value))
+			default:
+				// there are no modules, and no other filters
+				return true
+			}
+		})
+	}
+
+	names := make([]string, 0, len(commands))
+	for _, cmd := range commands {
+		names = append(names, cmd.name)
+	}
+
+	c.w.WriteBulkStrings(names)
+	return nil
+}
+
+func keySpecFlags(cmd *command) []string {
+	if cmd.flags&flagWrite != 0 {
+		return []string{"RW", "UPDATE"}
+	}
+	return []string{"RO", "ACCESS"}
+}
+
+func writeCommandInfo(c *client, cmd *command) {
+	c.w.WriteArray(10)
+	c.w.WriteBulkString(cmd.name)
+	c.w.WriteInteger(int64(cmd.arity))
+
+	flags := cmd.flagNames()
+	c.w.WriteSet(len(flags))
+	for _, flag := range flags {
+		c.w.WriteSimpleString(flag)
+	}
+
+	c.w.WriteInteger(int64(cmd.firstKey))
+	c.w.WriteInteger(int64(cmd.lastKey))
+	c.w.WriteInteger(int64(cmd.keyStep))
+
+	categories := cmd.categories()
+	c.w.WriteSet(len(categories))
+	for _, category := range categories {
+		c.w.WriteSimpleString(category)
+	}
+
+	// tips
+	c.w.WriteArray(0)
+
+	// key specifications
+	if cmd.firstKey == 0 {
+		c.w.WriteArray(0)
+	} else {
+		lastKey := cmd.lastKey
+		if lastKey >= 0 {
+			lastKey -= cmd.firstKey
+		}
+
+		c.w.WriteArray(1)
+		c.w.WriteMap(3)
+		c.w.WriteBulkString("flags")
+		c.w.WriteSet(len(keySpecFlags(cmd)))
+		for _, flag := range keySpecFlags(cmd) {
+			c.w.WriteSimpleString(flag)
+		}
+		c.w.WriteBulkString("begin_search")
+		c.w.WriteMap(2)
+		c.w.WriteBulkString("type")
+		c.w.WriteBulkString("index")
+		c.w.WriteBulkString("spec")
+		c.w.WriteMap(1)
+		c.w.WriteBulkString("index")
+		c.w.WriteInteger(int64(cmd.firstKey))
+		c.w.WriteBulkString("find_keys")
+		c.w.WriteMap(2)
+		c.w.WriteBulkString("type")
+		c.w.WriteBulkString("range")
+		c.w.WriteBulkString("spec")
+		c.w.WriteMap(3)
+		c.w.WriteBulkString("lastkey")
+		c.w.WriteInteger(int64(lastKey))
+		c.w.WriteBulkString("keystep")
+		c.w.WriteInteger(int64(cmd.keyStep))
+		c.w.WriteBulkString("limit")
+		c.w.WriteInteger(0)
+	}
+
+	// subcommands
+	c.w.WriteArray(0)
+}
@@ -6,3 +6,17 @@ var ErrInvalidId = errors.New(
 	"ERR The ID specified in XADD is equal or smaller than the target stream top item",
 )
 var ErrGreaterThanZero = errors.New("ERR The ID specified in XADD must be greater than 0-0")
+var ErrNoProto = errors.New("NOPROTO unsupported protocol version")
+var ErrProtoVersion = errors.New("ERR Protocol version is not an integer or out of range")
+var ErrWrongPass = errors.New("WRONGPASS invalid username-password pair or user is disabled.")
+var ErrClientName = errors.New(
+	"ERR Client names cannot contain spaces, newlines or special characters.",
+)
+var ErrSyntax = errors.New("ERR syntax error")
+var ErrNotInteger = errors.New("ERR value is not an integer or out of range")
+var ErrWrongType = errors.New(
+	"WRONGTYPE Operation against a key holding the wrong kind of value",
+)
+var ErrInvalidStreamId = errors.New(
+	"ERR Invalid stream ID specified as stream command argument",
+)
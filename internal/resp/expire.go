@@ -0,0 +1,324 @@
+package resp
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"nishojib/goredis/internal/parser"
+)
+
+const (
+	// activeExpireHz is how many times a second the active expire cycle
+	// runs, as hz defaults to in Redis.
+	activeExpireHz = 10
+
+	// activeExpireKeysPerLoop is the number of keys with a time to live each
+	// loop of the cycle samples.
+	activeExpireKeysPerLoop = 20
+
+	// activeExpireAcceptableStale is the percentage of expired keys in a
+	// sample under which the cycle stops looking for more.
+	activeExpireAcceptableStale = 10
+
+	// activeExpireCPUPercent is the share of its period a cycle may take.
+	activeExpireCPUPercent = 25
+)
+
+// expireStats are the figures about expiring keys INFO reports.
+type expireStats struct {
+	expiredKeys    int64
+	stalePerc      float64
+	timeCapReached int64
+	cycleTime      time.Duration
+
+	// avgTTL is the average time to live in milliseconds of the keys the
+	// cycle samples, weighted towards the latest ones
+	avgTTL int64
+}
+
+// expireIfNeeded removes key once its time to live has passed, and reports
+// whether the key is to be treated as missing.
+//
+// A replica leaves removing expired keys to its master, whose DEL keeps both
+// holding the same keys. Until it comes, reads report the key as missing and
+// commands from the master still see it. Only a write of the replica's own
+// removes it, as that would replace the value anyway.
+func (rn *RESPNode) expireIfNeeded(key string) bool {
+	at, ok := rn.expires.Load(key)
+	if !ok || time.Now().UnixMilli() <= at {
+		return false
+	}
+
+	if rn.IsSlave {
+		c := rn.current
+		if c != nil && c.master {
+			return false
+		}
+		if c == nil || c.cmd == nil || c.cmd.flags&flagWrite == 0 {
+			return true
+		}
+	}
+
+	rn.expireKey(key)
+	return true
+}
+
+// expireKey removes key, whose time to live has passed. A master tells its
+// replicas, which do not run the active expire cycle themselves.
+func (rn *RESPNode) expireKey(key string) {
+	// without its index entry deleteKey sees the key as it still is
+	rn.expires.Delete(key)
+	rn.deleteKey(key)
+	rn.expireStats.expiredKeys++
+
+	if !rn.IsSlave {
+		rn.propToSlaves(parser.EncodeArray([]string{"del", key}))
+	}
+}
+
+// ActiveExpire runs the active expire cycle activeExpireHz times a second,
+// so that keys are removed once they expire even if nothing looks them up.
+func (rn *RESPNode) ActiveExpire() {
+	ticker := time.NewTicker(time.Second / activeExpireHz)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		rn.activeExpireCycle()
+	}
+}
+
+// activeExpireCycle samples keys with a time to live and removes the expired
+// ones. It keeps sampling for as long as samples turn out to be mostly
+// expired, within activeExpireCPUPercent of its period.
+func (rn *RESPNode) activeExpireCycle() {
+	rn.mu.Lock()
+	defer rn.mu.Unlock()
+
+	start := time.Now()
+	limit := time.Second / activeExpireHz * activeExpireCPUPercent / 100
+
+	sampled, expired := 0, 0
+	var ttlSum, ttlSamples int64
+
+	for {
+		keys := rn.expires.Sample(activeExpireKeysPerLoop)
+		if len(keys) == 0 {
+			break
+		}
+
+		now := time.Now().UnixMilli()
+		loopExpired := 0
+		for _, key := range keys {
+			at, _ := rn.expires.Load(key)
+			if now > at {
+				rn.expireKey(key)
+				loopExpired++
+				continue
+			}
+
+			ttlSum += at - now
+			ttlSamples++
+		}
+
+		sampled += len(keys)
+		expired += loopExpired
+
+		if time.Since(start) > limit {
+			rn.expireStats.timeCapReached++
+			break
+		}
+
+		if loopExpired*100 <= len(keys)*activeExpireAcceptableStale {
+			break
+		}
+	}
+
+	rn.expireStats.cycleTime += time.Since(start)
+
+	// both averages lean on the latest cycles the way they do in Redis
+	perc := 0.0
+	if sampled > 0 {
+		perc = float64(expired) / float64(sampled)
+	}
+	rn.expireStats.stalePerc = perc*0.05 + rn.expireStats.stalePerc*0.95
+
+	if ttlSamples > 0 {
+		avg := ttlSum / ttlSamples
+		if rn.expireStats.avgTTL == 0 {
+			rn.expireStats.avgTTL = avg
+		} else {
+			rn.expireStats.avgTTL = rn.expireStats.avgTTL/50*49 + avg/50
+		}
+	}
+}
+
+func (rn *RESPNode) handleExpire(c *client, args [][]byte) error {
+	return rn.expire(c, "expire", args, 1000, true)
+}
+
+func (rn *RESPNode) handlePExpire(c *client, args [][]byte) error {
+	return rn.expire(c, "pexpire", args, 1, true)
+}
+
+func (rn *RESPNode) handleExpireAt(c *client, args [][]byte) error {
+	return rn.expire(c, "expireat", args, 1000, false)
+}
+
+func (rn *RESPNode) handlePExpireAt(c *client, args [][]byte) error {
+	return rn.expire(c, "pexpireat", args, 1, false)
+}
+
+// expireTime turns the time argument of EXPIRE and its variants, given in
+// units of unit milliseconds, into a unix time in milliseconds. It reports
+// false when the result does not fit in 64 bits.
+func expireTime(n int64, unit int64, relative bool) (int64, bool) {
+	if n > math.MaxInt64/unit || n < math.MinInt64/unit {
+		return 0, false
+	}
+	n *= unit
+
+	if relative {
+		now := time.Now().UnixMilli()
+		if n > math.MaxInt64-now {
+			return 0, false
+		}
+		n += now
+	}
+
+	return n, true
+}
+
+// expire implements EXPIRE, PEXPIRE, EXPIREAT and PEXPIREAT. Replicas always
+// get the deadline, so that their time to live does not drift.
+func (rn *RESPNode) expire(c *client, name string, args [][]byte, unit int64, relative bool) error {
+	key := string(args[0])
+
+	n, err := parseInt(args[1])
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	var nx, xx, gt, lt bool
+	for _, arg := range args[2:] {
+		switch strings.ToUpper(string(arg)) {
+		case "NX":
+			nx = true
+		case "XX":
+			xx = true
+		case "GT":
+			gt = true
+		case "LT":
+			lt = true
+		default:
+			c.w.WriteError(fmt.Sprintf("ERR Unsupported option %s", arg))
+			return nil
+		}
+	}
+
+	if nx && (xx || gt || lt) {
+		c.w.WriteError("ERR NX and XX, GT or LT options at the same time are not compatible")
+		return nil
+	}
+
+	if gt && lt {
+		c.w.WriteError("ERR GT and LT options at the same time are not compatible")
+		return nil
+	}
+
+	at, ok := expireTime(n, unit, relative)
+	if !ok {
+		c.w.WriteError(fmt.Sprintf("ERR invalid expire time in '%s' command", name))
+		return nil
+	}
+
+	if rn.keyType(key) == "none" {
+		c.w.WriteInteger(0)
+		return nil
+	}
+
+	// a key without a time to live counts as one that never expires
+	current := rn.expiryOf(key)
+	if (nx && current != -1) || (xx && current == -1) ||
+		(gt && (current == -1 || at <= current)) ||
+		(lt && current != -1 && at >= current) {
+		c.w.WriteInteger(0)
+		return nil
+	}
+
+	// a deadline in the past deletes the key, except on replicas, which wait
+	// for the master to delete it
+	if at <= time.Now().UnixMilli() && !rn.IsSlave {
+		rn.deleteKey(key)
+		c.dirty++
+		c.rewrite([]string{"del", key})
+
+		c.w.WriteInteger(1)
+		return nil
+	}
+
+	rn.setExpiry(key, at)
+	c.dirty++
+	c.rewrite([]string{"pexpireat", key, strconv.FormatInt(at, 10)})
+
+	c.w.WriteInteger(1)
+	return nil
+}
+
+func (rn *RESPNode) handleTTL(c *client, args [][]byte) error {
+	return rn.ttl(c, string(args[0]), 1000, false)
+}
+
+func (rn *RESPNode) handlePTTL(c *client, args [][]byte) error {
+	return rn.ttl(c, string(args[0]), 1, false)
+}
+
+func (rn *RESPNode) handleExpireTime(c *client, args [][]byte) error {
+	return rn.ttl(c, string(args[0]), 1000, true)
+}
+
+func (rn *RESPNode) handlePExpireTime(c *client, args [][]byte) error {
+	return rn.ttl(c, string(args[0]), 1, true)
+}
+
+// ttl implements TTL, PTTL, EXPIRETIME and PEXPIRETIME. It replies in units
+// of unit milliseconds with the time key has left to live or, when absolute
+// is set, the unix time it expires at. It replies with -2 when the key does
+// not exist and -1 when it never expires.
+func (rn *RESPNode) ttl(c *client, key string, unit int64, absolute bool) error {
+	if rn.keyType(key) == "none" {
+		c.w.WriteInteger(-2)
+		return nil
+	}
+
+	at := rn.expiryOf(key)
+	if at == -1 {
+		c.w.WriteInteger(-1)
+		return nil
+	}
+
+	if !absolute {
+		at = max(at-time.Now().UnixMilli(), 0)
+	}
+
+	c.w.WriteInteger((at + unit/2) / unit)
+	return nil
+}
+
+func (rn *RESPNode) handlePersist(c *client, args [][]byte) error {
+	key := string(args[0])
+
+	if rn.keyType(key) == "none" || rn.expiryOf(key) == -1 {
+		c.w.WriteInteger(0)
+		return nil
+	}
+
+	rn.expires.Delete(key)
+	c.dirty++
+
+	c.w.WriteInteger(1)
+	return nil
+}
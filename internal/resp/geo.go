@@ -0,0 +1,501 @@
+package resp
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"nishojib/goredis/internal/types"
+)
+
+var geoUnits = map[string]float64{"m": 1, "km": 1000, "ft": 0.3048, "mi": 1609.34}
+
+const errGeoUnit = "ERR unsupported unit provided. please use M, KM, FT, MI"
+
+var errGeoMember = errors.New("ERR could not decode requested zset member")
+
+// parseGeoPosition parses a longitude and a latitude, replying with an error
+// and returning false when they are not a valid position.
+func parseGeoPosition(c *client, lonArg []byte, latArg []byte) (float64, float64, bool) {
+	lon, ok := parseFloat(string(lonArg))
+	if !ok {
+		c.w.WriteError(errNotFloat.Error())
+		return 0, 0, false
+	}
+
+	lat, ok := parseFloat(string(latArg))
+	if !ok {
+		c.w.WriteError(errNotFloat.Error())
+		return 0, 0, false
+	}
+
+	if _, ok := types.GeoEncode(lon, lat); !ok {
+		c.w.WriteError(fmt.Sprintf("ERR invalid longitude,latitude pair %f,%f", lon, lat))
+		return 0, 0, false
+	}
+
+	return lon, lat, true
+}
+
+// formatGeoDistance formats a distance with the four decimals geo commands
+// reply with.
+func formatGeoDistance(distance float64) string {
+	return strconv.FormatFloat(distance, 'f', 4, 64)
+}
+
+func writeGeoPosition(c *client, lon float64, lat float64) {
+	c.w.WriteArray(2)
+	c.w.WriteDouble(lon)
+	c.w.WriteDouble(lat)
+}
+
+// handleGeoAdd adds the members to the sorted set at key with their geohash
+// as score, which ZADD does the rest of.
+func (rn *RESPNode) handleGeoAdd(c *client, args [][]byte) error {
+	zaddArgs := [][]byte{args[0]}
+
+	i := 1
+	for ; i < len(args); i++ {
+		option := strings.ToUpper(string(args[i]))
+		if option != "NX" && option != "XX" && option != "CH" {
+			break
+		}
+		zaddArgs = append(zaddArgs, args[i])
+	}
+
+	triples := args[i:]
+	if len(triples) == 0 || len(triples)%3 != 0 {
+		c.w.WriteError("ERR syntax error. Try GEOADD key [x1] [y1] [name1] [x2] [y2] [name2] ... ")
+		return nil
+	}
+
+	for j := 0; j < len(triples); j += 3 {
+		lon, lat, ok := parseGeoPosition(c, triples[j], triples[j+1])
+		if !ok {
+			return nil
+		}
+
+		score, _ := types.GeoEncode(lon, lat)
+		zaddArgs = append(zaddArgs, []byte(strconv.FormatUint(score, 10)), triples[j+2])
+	}
+
+	return rn.handleZAdd(c, zaddArgs)
+}
+
+func (rn *RESPNode) handleGeoDist(c *client, args [][]byte) error {
+	unit := 1.0
+	if len(args) == 4 {
+		var ok bool
+		if unit, ok = geoUnits[strings.ToLower(string(args[3]))]; !ok {
+			c.w.WriteError(errGeoUnit)
+			return nil
+		}
+	} else if len(args) > 4 {
+		c.w.WriteError(ErrSyntax.Error())
+		return nil
+	}
+
+	zset, ok, err := rn.getZSet(string(args[0]))
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	if !ok {
+		c.w.WriteNull()
+		return nil
+	}
+
+	score1, ok1 := zset.Score(string(args[1]))
+	score2, ok2 := zset.Score(string(args[2]))
+	if !ok1 || !ok2 {
+		c.w.WriteNull()
+		return nil
+	}
+
+	lon1, lat1 := types.GeoDecode(uint64(score1))
+	lon2, lat2 := types.GeoDecode(uint64(score2))
+
+	c.w.WriteBulkString(formatGeoDistance(types.GeoDistance(lon1, lat1, lon2, lat2) / unit))
+	return nil
+}
+
+func (rn *RESPNode) handleGeoHash(c *client, args [][]byte) error {
+	zset, ok, err := rn.getZSet(string(args[0]))
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	c.w.WriteArray(len(args) - 1)
+	for _, member := range args[1:] {
+		if !ok {
+			c.w.WriteNull()
+			continue
+		}
+
+		score, found := zset.Score(string(member))
+		if !found {
+			c.w.WriteNull()
+			continue
+		}
+		c.w.WriteBulkString(types.GeoHashString(uint64(score)))
+	}
+
+	return nil
+}
+
+func (rn *RESPNode) handleGeoPos(c *client, args [][]byte) error {
+	zset, ok, err := rn.getZSet(string(args[0]))
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	c.w.WriteArray(len(args) - 1)
+	for _, member := range args[1:] {
+		if !ok {
+			c.w.WriteNullArray()
+			continue
+		}
+
+		score, found := zset.Score(string(member))
+		if !found {
+			c.w.WriteNullArray()
+			continue
+		}
+		lon, lat := types.GeoDecode(uint64(score))
+		writeGeoPosition(c, lon, lat)
+	}
+
+	return nil
+}
+
+// geoSearchOptions are the arguments of GEOSEARCH and GEOSEARCHSTORE. Sort is
+// 1 for ASC, -1 for DESC and 0 when not given, and count is 0 for no limit.
+type geoSearchOptions struct {
+	shape      types.GeoShape
+	unit       float64
+	fromMember string
+	fromLonLat bool
+	byRadius   bool
+	byBox      bool
+	sort       int
+	count      int64
+	any        bool
+	withCoord  bool
+	withDist   bool
+	withHash   bool
+	storeDist  bool
+}
+
+// parseGeoSearchOptions parses the arguments following the source key of
+// GEOSEARCH, or of GEOSEARCHSTORE when store is set. It replies with an
+// error and returns false when they are invalid.
+func parseGeoSearchOptions(c *client, args [][]byte, store bool) (geoSearchOptions, bool) {
+	opts := geoSearchOptions{}
+	fromMember := false
+
+	parseUnit := func(arg []byte) bool {
+		unit, ok := geoUnits[strings.ToLower(string(arg))]
+		if !ok {
+			c.w.WriteError(errGeoUnit)
+			return false
+		}
+		opts.unit = unit
+		return true
+	}
+
+	for i := 0; i < len(args); i++ {
+		option := strings.ToUpper(string(args[i]))
+		left := len(args) - i - 1
+
+		switch {
+		case option == "FROMMEMBER" && left >= 1:
+			if opts.fromLonLat || fromMember {
+				c.w.WriteError(ErrSyntax.Error())
+				return opts, false
+			}
+			opts.fromMember, fromMember = string(args[i+1]), true
+			i++
+
+		case option == "FROMLONLAT" && left >= 2:
+			if opts.fromLonLat || fromMember {
+				c.w.WriteError(ErrSyntax.Error())
+				return opts, false
+			}
+
+			lon, lat, ok := parseGeoPosition(c, args[i+1], args[i+2])
+			if !ok {
+				return opts, false
+			}
+			opts.shape.Lon, opts.shape.Lat, opts.fromLonLat = lon, lat, true
+			i += 2
+
+		case option == "BYRADIUS" && left >= 2:
+			if opts.byRadius || opts.byBox {
+				c.w.WriteError(ErrSyntax.Error())
+				return opts, false
+			}
+
+			radius, ok := parseFloat(string(args[i+1]))
+			if !ok {
+				c.w.WriteError("ERR need numeric radius")
+				return opts, false
+			}
+			if radius < 0 {
+				c.w.WriteError("ERR radius cannot be negative")
+				return opts, false
+			}
+			if !parseUnit(args[i+2]) {
+				return opts, false
+			}
+			opts.shape.Radius, opts.byRadius = radius*opts.unit, true
+			i += 2
+
+		case option == "BYBOX" && left >= 3:
+			if opts.byRadius || opts.byBox {
+				c.w.WriteError(ErrSyntax.Error())
+				return opts, false
+			}
+
+			width, ok := parseFloat(string(args[i+1]))
+			if !ok {
+				c.w.WriteError("ERR need numeric width")
+				return opts, false
+			}
+			height, ok := parseFloat(string(args[i+2]))
+			if !ok {
+				c.w.WriteError("ERR need numeric height")
+				return opts, false
+			}
+			if width < 0 || height < 0 {
+				c.w.WriteError("ERR height or width cannot be negative")
+				return opts, false
+			}
+			if !parseUnit(args[i+3]) {
+				return opts, false
+			}
+			opts.shape.Box = true
+			opts.shape.Width, opts.shape.Height = width*opts.unit, height*opts.unit
+			opts.byBox = true
+			i += 3
+
+		case option == "ASC":
+			opts.sort = 1
+		case option == "DESC":
+			opts.sort = -1
+
+		case option == "COUNT" && left >= 1:
+			n, err := parseInt(args[i+1])
+			if err != nil {
+				c.w.WriteError(err.Error())
+				return opts, false
+			}
+			if n <= 0 {
+				c.w.WriteError("ERR COUNT must be > 0")
+				return opts, false
+			}
+			opts.count = n
+			i++
+
+			if i+1 < len(args) && strings.EqualFold(string(args[i+1]), "any") {
+				opts.any = true
+				i++
+			}
+
+		case option == "WITHCOORD" && !store:
+			opts.withCoord = true
+		case option == "WITHDIST" && !store:
+			opts.withDist = true
+		case option == "WITHHASH" && !store:
+			opts.withHash = true
+		case option == "STOREDIST" && store:
+			opts.storeDist = true
+
+		case option == "ANY":
+			c.w.WriteError("ERR the ANY argument requires COUNT argument")
+			return opts, false
+		default:
+			c.w.WriteError(ErrSyntax.Error())
+			return opts, false
+		}
+	}
+
+	command := "GEOSEARCH"
+	if store {
+		command = "GEOSEARCHSTORE"
+	}
+
+	if !fromMember && !opts.fromLonLat {
+		c.w.WriteError("ERR exactly one of FROMMEMBER or FROMLONLAT can be specified for " + command)
+		return opts, false
+	}
+
+	if !opts.byRadius && !opts.byBox {
+		c.w.WriteError("ERR exactly one of BYRADIUS and BYBOX can be specified for " + command)
+		return opts, false
+	}
+
+	// the closest members can only be picked once they are sorted
+	if opts.count > 0 && opts.sort == 0 && !opts.any {
+		opts.sort = 1
+	}
+
+	return opts, true
+}
+
+// geoPoint is a member found by a geo search, with its distance from the
+// center of the search in meters.
+type geoPoint struct {
+	member   string
+	score    float64
+	distance float64
+	lon, lat float64
+}
+
+// geoSearch returns the members of zset within the shape of opts, whose
+// center is resolved first when it is a member. It fails when that member is
+// not in zset.
+func geoSearch(zset *types.ZSet, opts *geoSearchOptions) ([]geoPoint, error) {
+	if !opts.fromLonLat {
+		score, ok := zset.Score(opts.fromMember)
+		if !ok {
+			return nil, errGeoMember
+		}
+		opts.shape.Lon, opts.shape.Lat = types.GeoDecode(uint64(score))
+	}
+
+	points := []geoPoint{}
+
+search:
+	for _, r := range opts.shape.Ranges() {
+		for _, m := range zset.Range(r, 0, -1, false) {
+			lon, lat := types.GeoDecode(uint64(m.Score))
+
+			distance, ok := opts.shape.Contains(lon, lat)
+			if !ok {
+				continue
+			}
+
+			points = append(points, geoPoint{m.Member, m.Score, distance, lon, lat})
+			if opts.any && int64(len(points)) == opts.count {
+				break search
+			}
+		}
+	}
+
+	if opts.sort != 0 {
+		slices.SortStableFunc(points, func(a, b geoPoint) int {
+			if a.distance < b.distance {
+				return -opts.sort
+			}
+			if a.distance > b.distance {
+				return opts.sort
+			}
+			return 0
+		})
+	}
+
+	if opts.count > 0 && int64(len(points)) > opts.count {
+		points = points[:opts.count]
+	}
+
+	return points, nil
+}
+
+func (rn *RESPNode) handleGeoSearch(c *client, args [][]byte) error {
+	opts, ok := parseGeoSearchOptions(c, args[1:], false)
+	if !ok {
+		return nil
+	}
+
+	zset, ok, err := rn.getZSet(string(args[0]))
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	if !ok {
+		c.w.WriteArray(0)
+		return nil
+	}
+
+	points, err := geoSearch(zset, &opts)
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	with := 0
+	for _, flag := range []bool{opts.withDist, opts.withHash, opts.withCoord} {
+		if flag {
+			with++
+		}
+	}
+
+	c.w.WriteArray(len(points))
+	for _, p := range points {
+		if with == 0 {
+			c.w.WriteBulkString(p.member)
+			continue
+		}
+
+		c.w.WriteArray(1 + with)
+		c.w.WriteBulkString(p.member)
+		if opts.withDist {
+			c.w.WriteBulkString(formatGeoDistance(p.distance / opts.unit))
+		}
+		if opts.withHash {
+			c.w.WriteInteger(int64(p.score))
+		}
+		if opts.withCoord {
+			writeGeoPosition(c, p.lon, p.lat)
+		}
+	}
+
+	return nil
+}
+
+func (rn *RESPNode) handleGeoSearchStore(c *client, args [][]byte) error {
+	destination := string(args[0])
+
+	opts, ok := parseGeoSearchOptions(c, args[2:], true)
+	if !ok {
+		return nil
+	}
+
+	zset, ok, err := rn.getZSet(string(args[1]))
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	points := []geoPoint{}
+	if ok {
+		if points, err = geoSearch(zset, &opts); err != nil {
+			c.w.WriteError(err.Error())
+			return nil
+		}
+	}
+
+	result := types.NewZSet()
+	for _, p := range points {
+		score := p.score
+		if opts.storeDist {
+			score = p.distance / opts.unit
+		}
+		result.Add(p.member, score)
+	}
+
+	rn.deleteKey(destination)
+	if result.Len() > 0 {
+		rn.zsetCache.Store(destination, result)
+	}
+	c.dirty++
+
+	c.w.WriteInteger(int64(result.Len()))
+	return nil
+}
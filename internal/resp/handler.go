@@ -1,7 +1,6 @@
 package resp
 
 import (
-	"bytes"
 	"fmt"
 	"net"
 	"strconv"
@@ -9,77 +8,144 @@ import (
 	"time"
 
 	"nishojib/goredis/internal/parser"
-	"nishojib/goredis/internal/rdb"
 	"nishojib/goredis/internal/types"
 )
 
+const redisVersion = "7.2.0"
+
 const emptyRDB = "UkVESVMwMDEx+glyZWRpcy12ZXIFNy4yLjD6CnJlZGlzLWJpdHPAQPoFY3RpbWXCbQi8ZfoIdXNlZC1tZW3CsMQQAPoIYW9mLWJhc2XAAP/wbjv+wP9aog=="
 
-func (rn *RESPNode) handlePing(conn net.Conn) error {
-	err := sendResponse(conn, parser.EncodeSimpleString("PONG"))
-	if err != nil {
-		return err
+func (rn *RESPNode) handlePing(c *client, args [][]byte) error {
+	if len(args) > 0 {
+		c.w.WriteBulkString(string(args[0]))
+		return nil
 	}
 
+	c.w.WriteSimpleString("PONG")
 	return nil
 }
 
-func (rn *RESPNode) handleEcho(conn net.Conn, payload string) error {
-	err := sendResponse(conn, parser.EncodeBulkString(payload))
-	if err != nil {
-		return err
-	}
-
+func (rn *RESPNode) handleEcho(c *client, args [][]byte) error {
+	c.w.WriteBulkString(string(args[0]))
 	return nil
 }
 
-func (rn *RESPNode) handleSet(conn net.Conn, key string, value string, expMillSec int64) error {
-	if expMillSec != -1 {
-		go rn.removeKeyAfter(key, expMillSec)
+func (rn *RESPNode) handleSet(c *client, args [][]byte) error {
+	key, value := string(args[0]), string(args[1])
+
+	var nx, xx, get, keepTTL bool
+	var expiry int64 = -1
+	expireOption := ""
+
+	for i := 2; i < len(args); i++ {
+		option := strings.ToUpper(string(args[i]))
+
+		switch {
+		case option == "NX" && !xx:
+			nx = true
+		case option == "XX" && !nx:
+			xx = true
+		case option == "GET":
+			get = true
+		case option == "KEEPTTL" && expireOption == "":
+			keepTTL = true
+		case (option == "EX" || option == "PX" || option == "EXAT" || option == "PXAT") &&
+			expireOption == "" && !keepTTL && i+1 < len(args):
+			n, err := parseInt(args[i+1])
+			if err != nil {
+				c.w.WriteError(err.Error())
+				return nil
+			}
+
+			expiry, err = absoluteExpiry(option, n)
+			if err != nil {
+				c.w.WriteError("ERR invalid expire time in 'set' command")
+				return nil
+			}
+
+			expireOption = option
+			i++
+		default:
+			c.w.WriteError(ErrSyntax.Error())
+			return nil
+		}
 	}
 
-	rn.cache.Store(key, types.NewItem(value, "string", expMillSec))
+	keyType := rn.keyType(key)
 
-	if !rn.IsSlave {
-		err := sendResponse(conn, parser.EncodeSimpleString("OK"))
-		if err != nil {
-			return err
+	old, exists, err := rn.getString(key)
+	if err != nil && get {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	reply := func() {
+		switch {
+		case get && exists:
+			c.w.WriteBulk(old.Value)
+		case get:
+			c.w.WriteNull()
+		default:
+			c.w.WriteSimpleString("OK")
 		}
+	}
 
-		err = rn.propToSlaves(parser.EncodeArray([]string{"SET", key, string(value)}))
-		if err != nil {
-			fmt.Println("propagated a command. got an error", err)
-			return err
+	if (nx && keyType != "none") || (xx && keyType == "none") {
+		if !get {
+			c.w.WriteNull()
+			return nil
 		}
+		reply()
+		return nil
+	}
 
-		fmt.Println("propagates a command")
+	if keepTTL {
+		expiry = rn.expiryOf(key)
 	}
 
-	if rn.IsSlave {
+	rn.setString(key, types.Item{Value: args[1], Type: "string"}, expiry)
+	c.dirty++
+
+	// relative expiries would drift on replicas, so they get the deadline
+	propagated := []string{"set", key, value}
+	if keepTTL {
+		propagated = append(propagated, "KEEPTTL")
+	} else if expiry != -1 {
+		propagated = append(propagated, "PXAT", strconv.FormatInt(expiry, 10))
+	}
+	c.rewrite(propagated)
+
+	reply()
+
+	if c.master {
 		fmt.Println("got a command from MASTER")
 	}
 
 	return nil
 }
 
-func (rn *RESPNode) handleGet(conn net.Conn, key string) error {
-	item := rn.getItemFromStore(key)
+func (rn *RESPNode) handleGet(c *client, args [][]byte) error {
+	item, ok, err := rn.getString(string(args[0]))
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
 
-	if item.Value == "" {
-		err := sendResponse(conn, parser.EncodeBulkString(""))
-		if err != nil {
-			return err
-		}
-	} else {
-		err := sendResponse(conn, parser.EncodeBulkString(string(item.Value)))
-		if err != nil {
-			return err
-		}
+	if !ok {
+		c.w.WriteNull()
+		return nil
 	}
+
+	c.w.WriteBulk(item.Value)
 	return nil
 }
 
-func (rn *RESPNode) handleInfo(conn net.Conn, arg string) error {
+func (rn *RESPNode) handleInfo(c *client, args [][]byte) error {
+	arg := ""
+	if len(args) > 0 {
+		arg = string(args[0])
+	}
+
 	if arg != "" {
 		switch strings.ToLower(arg) {
 		case "replication":
@@ -90,32 +156,67 @@ func (rn *RESPNode) handleInfo(conn net.Conn, arg string) error {
 				rn.MasterReplOffset,
 			)
 
-			err := sendResponse(conn, parser.EncodeBulkString(payload))
-			if err != nil {
-				return err
+			c.w.WriteVerbatimString("txt", payload)
+		case "stats":
+			stats := rn.expireStats
+			payload := fmt.Sprintf(
+				"expired_keys:%d\r\nexpired_stale_perc:%.2f\r\nexpired_time_cap_reached_count:%d\r\nexpire_cycle_cpu_milliseconds:%d",
+				stats.expiredKeys,
+				stats.stalePerc*100,
+				stats.timeCapReached,
+				stats.cycleTime.Milliseconds(),
+			)
+
+			c.w.WriteVerbatimString("txt", payload)
+		case "keyspace":
+			keys := 0
+			for _, ks := range rn.keyspaces() {
+				keys += ks.Len()
+			}
+
+			// like Redis, an empty database is left out
+			payload := ""
+			if keys > 0 {
+				payload = fmt.Sprintf(
+					"db0:keys=%d,expires=%d,avg_ttl=%d",
+					keys,
+					rn.expires.Len(),
+					rn.expireStats.avgTTL,
+				)
 			}
+
+			c.w.WriteVerbatimString("txt", payload)
+		default:
+			c.w.WriteVerbatimString("txt", "")
 		}
 	} else {
-		err := sendResponse(conn, parser.EncodeBulkString("role:master"))
-		if err != nil {
-			return err
-		}
+		c.w.WriteVerbatimString("txt", "role:master")
 	}
 
 	return nil
 }
 
-func (rn *RESPNode) handleReplconf(conn net.Conn, arg string) error {
+func (rn *RESPNode) handleReplconf(c *client, args [][]byte) error {
+	arg := ""
+	if len(args) > 0 {
+		arg = string(args[0])
+	}
+
 	switch strings.ToLower(arg) {
 	case "ack":
-		if rn.IsSlave && rn.handshakeDone {
+		if c.master {
 			return nil
 		}
 
 		rn.SlaveConns.numAck++
 
 		if rn.SlaveConns.numAck >= rn.SlaveConns.numWait {
-			rn.SlaveConns.waitChannel <- true
+			// nobody listens once WAIT timed out, and blocking here would
+			// hold up every other client
+			select {
+			case rn.SlaveConns.waitChannel <- true:
+			default:
+			}
 			sendResponse(
 				rn.SlaveConns.connWait,
 				parser.EncodeInteger(fmt.Sprintf("%d", rn.SlaveConns.numAck)),
@@ -124,223 +225,228 @@ func (rn *RESPNode) handleReplconf(conn net.Conn, arg string) error {
 			rn.SlaveConns.numWait = 0
 		}
 	case "getack":
+		// this is the only reply a replica sends to its master, so it skips
+		// the writer that discards every other one
 		bytesReceived := strconv.Itoa(rn.bytesProc.all - rn.bytesProc.currReq)
 		err := sendResponse(
-			conn,
+			c.conn,
 			parser.EncodeArray([]string{"REPLCONF", "ACK", bytesReceived}),
 		)
 		if err != nil {
 			return err
 		}
 	default:
-		err := sendResponse(conn, parser.EncodeSimpleString("OK"))
-		if err != nil {
-			return err
-		}
+		c.w.WriteSimpleString("OK")
 	}
 
 	return nil
 }
 
-func (rn *RESPNode) handlePsync(conn net.Conn) error {
+func (rn *RESPNode) handlePsync(c *client, args [][]byte) error {
 	rn.SlaveConns.mutex.Lock()
 	defer rn.SlaveConns.mutex.Unlock()
 
-	err := sendResponse(
-		conn,
-		parser.EncodeBulkString(fmt.Sprintf("FULLRESYNC %s 0", rn.MasterReplID)),
-	)
-	if err != nil {
-		return err
-	}
+	c.w.WriteBulkString(fmt.Sprintf("FULLRESYNC %s 0", rn.MasterReplID))
 
 	rdbFile, err := parser.EncodeRDBFile(emptyRDB)
 	if err != nil {
 		return err
 	}
 
-	err = sendResponse(conn, rdbFile)
-	if err != nil {
+	c.w.WriteRaw([]byte(rdbFile))
+
+	// propagated commands go out on the connection once the replica is
+	// registered, so the RDB file has to be out before
+	if err := c.w.Flush(); err != nil {
 		return err
 	}
 
 	if !rn.IsSlave {
-		rn.SlaveConns.conns = append(rn.SlaveConns.conns, conn)
+		rn.SlaveConns.conns = append(rn.SlaveConns.conns, newReplica(c.conn))
 	}
 
 	return nil
 }
 
-func (rn *RESPNode) handleUnknown(conn net.Conn) error {
-	err := sendResponse(conn, parser.EncodeSimpleString("Unknown command"))
-	if err != nil {
-		return err
+func (rn *RESPNode) handleUnknown(c *client, command types.Command) error {
+	var args strings.Builder
+	for _, arg := range command.Args {
+		fmt.Fprintf(&args, "'%.128s' ", arg)
 	}
 
+	c.w.WriteError(fmt.Sprintf(
+		"ERR unknown command '%.128s', with args beginning with: %s",
+		command.Name,
+		args.String(),
+	))
 	return nil
 }
 
-func (rn *RESPNode) handleWait(conn net.Conn, numReplicas int64, timeout int64) error {
-	rn.SlaveConns.numAck = 0
-	rn.SlaveConns.connWait = conn
+func (rn *RESPNode) handleWait(c *client, args [][]byte) error {
+	numReplicas, err := parseInt(args[0])
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
 
-	for _, replica := range rn.SlaveConns.conns {
-		if err := sendResponse(replica, parser.EncodeArray([]string{"REPLCONF", "GETACK", "*"})); err != nil {
-			fmt.Printf("Error replicating: %v\n", err)
-			continue
-		}
+	timeout, err := parseInt(args[1])
+	if err != nil {
+		c.w.WriteError("ERR timeout is not an integer or out of range")
+		return nil
+	}
+
+	if timeout < 0 {
+		c.w.WriteError("ERR timeout is negative")
+		return nil
 	}
 
+	rn.SlaveConns.numAck = 0
+	rn.SlaveConns.connWait = c.conn
+
+	// queued behind the writes, so that acks count what replicas applied
+	rn.propToSlaves(parser.EncodeArray([]string{"REPLCONF", "GETACK", "*"}))
+
 	if numReplicas == 0 {
-		if err := sendResponse(conn, parser.EncodeInteger(fmt.Sprintf("%d", len(rn.SlaveConns.conns)))); err != nil {
-			return err
-		}
+		c.w.WriteInteger(int64(len(rn.SlaveConns.conns)))
 		return nil
 	}
 
 	rn.SlaveConns.numWait = int(numReplicas)
 
-	go rn.waitTimeout(conn, timeout)
+	go rn.waitTimeout(c.conn, timeout)
 	return nil
 }
 
-func (rn *RESPNode) handleConfig(conn net.Conn, query string) error {
-	switch query {
-	case "dir":
-		return sendResponse(
-			conn,
-			parser.EncodeArray([]string{"dir", rn.RDBFile.Dir}),
-		)
-	case "dbfilename":
-		return sendResponse(
-			conn,
-			parser.EncodeArray([]string{"dbfilename", rn.RDBFile.DBFilename}),
-		)
-	default:
+func (rn *RESPNode) handleConfig(c *client, args [][]byte) error {
+	if !strings.EqualFold(string(args[0]), "get") {
+		c.w.WriteError(fmt.Sprintf(
+			"ERR unknown subcommand '%s'. Try CONFIG HELP.",
+			args[0],
+		))
 		return nil
 	}
-}
 
-func (rn *RESPNode) handleKeys(conn net.Conn, query []byte) error {
-	rdbValues, err := rdb.ParseRDBFile(rn.RDBFile.Dir, rn.RDBFile.DBFilename)
-	if err != nil {
-		return err
+	if len(args) < 2 {
+		c.w.WriteError("ERR wrong number of arguments for 'config|get' command")
+		return nil
 	}
 
-	if bytes.Equal(query, []byte("*")) {
-		if len(rdbValues) > 0 {
-			names := []string{}
+	params := [][2]string{
+		{"dir", rn.RDBFile.Dir},
+		{"dbfilename", rn.RDBFile.DBFilename},
+	}
 
-			for _, value := range rdbValues {
-				names = append(names, value.Name)
+	matches := [][2]string{}
+	for _, param := range params {
+		for _, pattern := range args[1:] {
+			if stringMatch(string(pattern), param[0], true) {
+				matches = append(matches, param)
+				break
 			}
-
-			return sendResponse(conn, parser.EncodeArray(names))
 		}
+	}
 
-		return sendResponse(conn, parser.EncodeBulkString(""))
+	c.w.WriteMap(len(matches))
+	for _, param := range matches {
+		c.w.WriteBulkString(param[0])
+		c.w.WriteBulkString(param[1])
 	}
 
 	return nil
 }
 
-func (rn *RESPNode) handleType(conn net.Conn, query string) error {
-	item := rn.getItemFromStore(query)
-	if item.Value == "" {
-		_, ok := rn.streamCache.Load(query)
-		if !ok {
-			return sendResponse(conn, parser.EncodeSimpleString("none"))
+func (rn *RESPNode) handleKeys(c *client, args [][]byte) error {
+	pattern := string(args[0])
+
+	keys := []string{}
+	for _, ks := range rn.keyspaces() {
+		for _, key := range ks.Keys() {
+			// expired strings linger in the cache until they are removed
+			if stringMatch(pattern, key, false) && rn.keyType(key) != "none" {
+				keys = append(keys, key)
+			}
 		}
-		return sendResponse(conn, parser.EncodeSimpleString("stream"))
 	}
 
-	return sendResponse(conn, parser.EncodeSimpleString(string(item.Type)))
+	c.w.WriteBulkStrings(keys)
+	return nil
 }
 
-func (rn *RESPNode) handleXAdd(conn net.Conn, args [][]byte) error {
-	storeKey := args[0]
-	streamKey := args[1]
-
-	items := []types.StreamItem{}
-
-	for i := 2; i < len(args); i += 2 {
-		items = append(items, types.StreamItem{
-			Key:   string(bytes.ToLower(args[i])),
-			Value: string(bytes.ToLower(args[i+1])),
-		})
-	}
-
-	if bytes.Equal(streamKey, []byte("0-0")) {
-		return sendResponse(conn, parser.EncodeSimpleError(ErrGreaterThanZero.Error()))
-	}
-
-	if bytes.Equal(streamKey, []byte("*")) {
-		currentMilliseconds := time.Now().UnixMilli()
-		streamKey = []byte(fmt.Sprintf("%d-%d", currentMilliseconds, 0))
-	}
+func (rn *RESPNode) handleType(c *client, args [][]byte) error {
+	c.w.WriteSimpleString(rn.keyType(string(args[0])))
+	return nil
+}
 
-	streamIdParts := strings.Split(string(streamKey), "-")
-	streamMilliseconds := streamIdParts[0]
-	streamSequence := streamIdParts[1]
+func (rn *RESPNode) handleHello(c *client, args [][]byte) error {
+	protocol := c.w.Protocol
 
-	stream, ok := rn.streamCache.Load(string(storeKey))
-	if !ok {
-		if streamSequence == "*" {
-			streamKey = []byte(fmt.Sprintf("%s-%d", streamMilliseconds, 1))
+	if len(args) > 0 {
+		version, err := strconv.Atoi(string(args[0]))
+		if err != nil {
+			c.w.WriteError(ErrProtoVersion.Error())
+			return nil
 		}
 
-		rn.streamCache.Store(string(storeKey), types.Stream{
-			Entries: []types.StreamEntry{{ID: string(streamKey), Items: items}},
-		})
-		return sendResponse(conn, parser.EncodeBulkString(string(streamKey)))
-	}
-
-	lastEntry := stream.Entries[len(stream.Entries)-1]
-	idParts := strings.Split(lastEntry.ID, "-")
-	milliseconds := idParts[0]
-	sequence := idParts[1]
-
-	if streamMilliseconds > milliseconds {
-		if streamSequence == "*" {
-			streamKey = []byte(fmt.Sprintf("%s-%s", streamMilliseconds, "0"))
+		if version != 2 && version != 3 {
+			c.w.WriteError(ErrNoProto.Error())
+			return nil
 		}
 
-		rn.streamCache.Store(string(storeKey), types.Stream{
-			Entries: append(stream.Entries, types.StreamEntry{ID: string(streamKey), Items: items}),
-		})
-		return sendResponse(conn, parser.EncodeBulkString(string(streamKey)))
+		protocol = version
 	}
 
-	if streamMilliseconds == milliseconds {
-		sequenceInt, err := strconv.Atoi(sequence)
-		if err != nil {
-			fmt.Println("Error converting sequence to int")
-		}
+	name := c.name
+	for i := 1; i < len(args); i++ {
+		option := strings.ToLower(string(args[i]))
 
-		var streamSequenceInt int
-		if streamSequence == "*" {
-			streamSequenceInt = sequenceInt + 1
-			streamKey = []byte(fmt.Sprintf("%s-%d", streamMilliseconds, streamSequenceInt))
-		} else {
-			streamSequenceInt, err = strconv.Atoi(streamSequence)
-			if err != nil {
-				fmt.Println("Error converting streamSequence to int")
+		switch {
+		case option == "auth" && i+2 < len(args):
+			// there are no users besides the default one, which has no password
+			if string(args[i+1]) != "default" {
+				c.w.WriteError(ErrWrongPass.Error())
+				return nil
+			}
+			i += 2
+		case option == "setname" && i+1 < len(args):
+			if strings.ContainsFunc(string(args[i+1]), func(r rune) bool {
+				return r <= ' ' || r > '~'
+			}) {
+				c.w.WriteError(ErrClientName.Error())
+				return nil
 			}
+			name = string(args[i+1])
+			i++
+		default:
+			c.w.WriteError(fmt.Sprintf("ERR Syntax error in HELLO option '%s'", args[i]))
+			return nil
 		}
+	}
 
-		if streamSequenceInt > sequenceInt {
-			rn.streamCache.Store(string(storeKey), types.Stream{
-				Entries: append(
-					stream.Entries,
-					types.StreamEntry{ID: string(streamKey), Items: items},
-				),
-			})
+	c.w.Protocol = protocol
+	c.name = name
 
-			return sendResponse(conn, parser.EncodeBulkString(string(streamKey)))
-		}
+	role := "master"
+	if rn.IsSlave {
+		role = "replica"
 	}
 
-	return sendResponse(conn, parser.EncodeSimpleError(ErrInvalidId.Error()))
+	c.w.WriteMap(7)
+	c.w.WriteBulkString("server")
+	c.w.WriteBulkString("redis")
+	c.w.WriteBulkString("version")
+	c.w.WriteBulkString(redisVersion)
+	c.w.WriteBulkString("proto")
+	c.w.WriteInteger(int64(c.w.Protocol))
+	c.w.WriteBulkString("id")
+	c.w.WriteInteger(c.id)
+	c.w.WriteBulkString("mode")
+	c.w.WriteBulkString("standalone")
+	c.w.WriteBulkString("role")
+	c.w.WriteBulkString(role)
+	c.w.WriteBulkString("modules")
+	c.w.WriteArray(0)
+
+	return nil
 }
 
 func (rn *RESPNode) waitTimeout(conn net.Conn, timeout int64) {
@@ -356,15 +462,101 @@ func (rn *RESPNode) waitTimeout(conn net.Conn, timeout int64) {
 	}
 }
 
-func (rn *RESPNode) getItemFromStore(key string) types.Item {
-	itemVal, ok := rn.cache.Load(key)
-	if !ok {
-		return types.Item{}
+// keyType returns the type of the value stored at key, or "none" when the key
+// does not exist.
+func (rn *RESPNode) keyType(key string) string {
+	if rn.expireIfNeeded(key) {
+		return "none"
+	}
+
+	if item, ok := rn.cache.Load(key); ok {
+		return item.Type
+	}
+
+	if _, ok := rn.streamCache.Load(key); ok {
+		return "stream"
+	}
+
+	if _, ok := rn.listCache.Load(key); ok {
+		return "list"
+	}
+
+	if _, ok := rn.hashCache.Load(key); ok {
+		return "hash"
+	}
+
+	if _, ok := rn.setCache.Load(key); ok {
+		return "set"
+	}
+
+	if _, ok := rn.zsetCache.Load(key); ok {
+		return "zset"
+	}
+
+	return "none"
+}
+
+// deleteKey removes key whatever the type of its value, and reports whether
+// it existed.
+func (rn *RESPNode) deleteKey(key string) bool {
+	keyType := rn.keyType(key)
+
+	for _, ks := range rn.keyspaces() {
+		ks.Delete(key)
+	}
+	rn.expires.Delete(key)
+
+	// blocked XREADGROUP calls on the stream must find out it is gone
+	if keyType == "stream" {
+		rn.streamReady.Broadcast()
+	}
+
+	return keyType != "none"
+}
+
+// getString returns the string stored at key. It fails with ErrWrongType when
+// the key holds a value of another type.
+func (rn *RESPNode) getString(key string) (types.Item, bool, error) {
+	if keyType := rn.keyType(key); keyType != "none" && keyType != "string" {
+		return types.Item{}, false, ErrWrongType
+	}
+
+	item, ok := rn.getItemFromStore(key)
+	return item, ok, nil
+}
+
+// setString stores item at key, replacing whatever the key held before, and
+// has it expire at the unix time in milliseconds expiry unless that is -1.
+func (rn *RESPNode) setString(key string, item types.Item, expiry int64) {
+	rn.deleteKey(key)
+
+	if expiry != -1 {
+		rn.setExpiry(key, expiry)
+	}
+
+	rn.cache.Store(key, item)
+}
+
+// getItemFromStore reports whether key holds a string that has not expired.
+// The value of a key that exists may still be the empty string.
+func (rn *RESPNode) getItemFromStore(key string) (types.Item, bool) {
+	if rn.expireIfNeeded(key) {
+		return types.Item{}, false
 	}
+	return rn.cache.Load(key)
+}
 
-	if itemVal.Expiry != -1 && itemVal.Expiry < time.Now().UnixMilli() {
-		return types.Item{}
+// expiryOf returns the unix time in milliseconds key expires at, or -1 when
+// it has no time to live.
+func (rn *RESPNode) expiryOf(key string) int64 {
+	at, ok := rn.expires.Load(key)
+	if !ok {
+		return -1
 	}
+	return at
+}
 
-	return itemVal
+// setExpiry has key expire at the unix time in milliseconds at.
+func (rn *RESPNode) setExpiry(key string, at int64) {
+	rn.expires.Store(key, at)
 }
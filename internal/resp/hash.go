@@ -0,0 +1,399 @@
+package resp
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"nishojib/goredis/internal/types"
+)
+
+// getHash returns the hash stored at key. It fails with ErrWrongType when
+// the key holds a value of another type.
+func (rn *RESPNode) getHash(key string) (types.Hash, bool, error) {
+	keyType := rn.keyType(key)
+	if keyType == "none" {
+		return nil, false, nil
+	}
+	if keyType != "hash" {
+		return nil, false, ErrWrongType
+	}
+
+	hash, ok := rn.hashCache.Load(key)
+	return hash, ok, nil
+}
+
+func (rn *RESPNode) handleHSet(c *client, args [][]byte) error {
+	key := string(args[0])
+
+	if len(args)%2 != 1 {
+		c.w.WriteError("ERR wrong number of arguments for 'hset' command")
+		return nil
+	}
+
+	hash, ok, err := rn.getHash(key)
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	if !ok {
+		hash = types.NewHash()
+		rn.hashCache.Store(key, hash)
+	}
+
+	added := 0
+	for i := 1; i < len(args); i += 2 {
+		field := string(args[i])
+		if _, ok := hash[field]; !ok {
+			added++
+		}
+		hash[field] = string(args[i+1])
+	}
+	c.dirty++
+
+	c.w.WriteInteger(int64(added))
+	return nil
+}
+
+func (rn *RESPNode) handleHSetNX(c *client, args [][]byte) error {
+	key, field := string(args[0]), string(args[1])
+
+	hash, ok, err := rn.getHash(key)
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	if _, exists := hash[field]; exists {
+		c.w.WriteInteger(0)
+		return nil
+	}
+
+	if !ok {
+		hash = types.NewHash()
+		rn.hashCache.Store(key, hash)
+	}
+
+	hash[field] = string(args[2])
+	c.dirty++
+
+	c.w.WriteInteger(1)
+	return nil
+}
+
+func (rn *RESPNode) handleHGet(c *client, args [][]byte) error {
+	hash, _, err := rn.getHash(string(args[0]))
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	value, ok := hash[string(args[1])]
+	if !ok {
+		c.w.WriteNull()
+		return nil
+	}
+
+	c.w.WriteBulkString(value)
+	return nil
+}
+
+func (rn *RESPNode) handleHMGet(c *client, args [][]byte) error {
+	hash, _, err := rn.getHash(string(args[0]))
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	c.w.WriteArray(len(args) - 1)
+	for _, field := range args[1:] {
+		value, ok := hash[string(field)]
+		if !ok {
+			c.w.WriteNull()
+			continue
+		}
+		c.w.WriteBulkString(value)
+	}
+
+	return nil
+}
+
+func (rn *RESPNode) handleHDel(c *client, args [][]byte) error {
+	key := string(args[0])
+
+	hash, ok, err := rn.getHash(key)
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	if !ok {
+		c.w.WriteInteger(0)
+		return nil
+	}
+
+	deleted := 0
+	for _, field := range args[1:] {
+		if _, ok := hash[string(field)]; ok {
+			delete(hash, string(field))
+			deleted++
+		}
+	}
+
+	if len(hash) == 0 {
+		rn.deleteKey(key)
+	}
+
+	if deleted > 0 {
+		c.dirty++
+	}
+
+	c.w.WriteInteger(int64(deleted))
+	return nil
+}
+
+func (rn *RESPNode) handleHGetAll(c *client, args [][]byte) error {
+	hash, _, err := rn.getHash(string(args[0]))
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	c.w.WriteMap(len(hash))
+	for field, value := range hash {
+		c.w.WriteBulkString(field)
+		c.w.WriteBulkString(value)
+	}
+
+	return nil
+}
+
+func (rn *RESPNode) handleHKeys(c *client, args [][]byte) error {
+	hash, _, err := rn.getHash(string(args[0]))
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	c.w.WriteArray(len(hash))
+	for field := range hash {
+		c.w.WriteBulkString(field)
+	}
+
+	return nil
+}
+
+func (rn *RESPNode) handleHVals(c *client, args [][]byte) error {
+	hash, _, err := rn.getHash(string(args[0]))
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	c.w.WriteArray(len(hash))
+	for _, value := range hash {
+		c.w.WriteBulkString(value)
+	}
+
+	return nil
+}
+
+func (rn *RESPNode) handleHLen(c *client, args [][]byte) error {
+	hash, _, err := rn.getHash(string(args[0]))
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	c.w.WriteInteger(int64(len(hash)))
+	return nil
+}
+
+func (rn *RESPNode) handleHExists(c *client, args [][]byte) error {
+	hash, _, err := rn.getHash(string(args[0]))
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	if _, ok := hash[string(args[1])]; ok {
+		c.w.WriteInteger(1)
+		return nil
+	}
+
+	c.w.WriteInteger(0)
+	return nil
+}
+
+func (rn *RESPNode) handleHStrlen(c *client, args [][]byte) error {
+	hash, _, err := rn.getHash(string(args[0]))
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	c.w.WriteInteger(int64(len(hash[string(args[1])])))
+	return nil
+}
+
+func (rn *RESPNode) handleHIncrBy(c *client, args [][]byte) error {
+	key, field := string(args[0]), string(args[1])
+
+	delta, err := parseInt(args[2])
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	hash, ok, err := rn.getHash(key)
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	var current int64
+	if value, exists := hash[field]; exists {
+		current, exists = parseStrictInt(value)
+		if !exists {
+			c.w.WriteError("ERR hash value is not an integer")
+			return nil
+		}
+	}
+
+	if (delta > 0 && current > math.MaxInt64-delta) ||
+		(delta < 0 && current < math.MinInt64-delta) {
+		c.w.WriteError("ERR increment or decrement would overflow")
+		return nil
+	}
+
+	current += delta
+
+	if !ok {
+		hash = types.NewHash()
+		rn.hashCache.Store(key, hash)
+	}
+
+	hash[field] = strconv.FormatInt(current, 10)
+	c.dirty++
+
+	c.w.WriteInteger(current)
+	return nil
+}
+
+func (rn *RESPNode) handleHIncrByFloat(c *client, args [][]byte) error {
+	key, field := string(args[0]), string(args[1])
+
+	incr, ok := parseFloat(string(args[2]))
+	if !ok {
+		c.w.WriteError("ERR value is not a valid float")
+		return nil
+	}
+
+	hash, ok, err := rn.getHash(key)
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	var current float64
+	if value, exists := hash[field]; exists {
+		current, exists = parseFloat(value)
+		if !exists {
+			c.w.WriteError("ERR hash value is not a float")
+			return nil
+		}
+	}
+
+	result := current + incr
+	if math.IsInf(result, 0) || math.IsNaN(result) {
+		c.w.WriteError("ERR increment would produce NaN or Infinity")
+		return nil
+	}
+
+	value := strconv.FormatFloat(result, 'f', -1, 64)
+
+	if !ok {
+		hash = types.NewHash()
+		rn.hashCache.Store(key, hash)
+	}
+
+	hash[field] = value
+	c.dirty++
+
+	// like INCRBYFLOAT, replicas get the result instead of the increment
+	c.rewrite([]string{"hset", key, field, value})
+
+	c.w.WriteBulkString(value)
+	return nil
+}
+
+func (rn *RESPNode) handleHRandField(c *client, args [][]byte) error {
+	hash, _, err := rn.getHash(string(args[0]))
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	if len(args) == 1 {
+		if len(hash) == 0 {
+			c.w.WriteNull()
+			return nil
+		}
+
+		c.w.WriteBulkString(types.RandomKeys(hash, 1, false)[0])
+		return nil
+	}
+
+	count, err := parseInt(args[1])
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	withValues := false
+	if len(args) == 3 {
+		if !strings.EqualFold(string(args[2]), "withvalues") {
+			c.w.WriteError(ErrSyntax.Error())
+			return nil
+		}
+		withValues = true
+	} else if len(args) > 3 {
+		c.w.WriteError(ErrSyntax.Error())
+		return nil
+	}
+
+	// a positive count picks distinct fields, a negative one may repeat them
+	var picked []string
+	if count >= 0 {
+		picked = types.RandomKeys(hash, int(min(count, math.MaxInt32)), false)
+	} else {
+		if count < -math.MaxInt32 && len(hash) > 0 {
+			c.w.WriteError("ERR value is out of range")
+			return nil
+		}
+		picked = types.RandomKeys(hash, int(-count), true)
+	}
+
+	if !withValues {
+		c.w.WriteBulkStrings(picked)
+		return nil
+	}
+
+	// RESP3 pairs every field with its value, RESP2 flattens them
+	if c.w.Protocol == 3 {
+		c.w.WriteArray(len(picked))
+	} else {
+		c.w.WriteArray(2 * len(picked))
+	}
+
+	for _, field := range picked {
+		if c.w.Protocol == 3 {
+			c.w.WriteArray(2)
+		}
+		c.w.WriteBulkString(field)
+		c.w.WriteBulkString(hash[field])
+	}
+
+	return nil
+}
@@ -1,6 +1,12 @@
 package resp
 
-import "net"
+import (
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
 
 func sendResponse(conn net.Conn, payload string) error {
 	_, err := conn.Write([]byte(payload))
@@ -9,3 +15,166 @@ func sendResponse(conn net.Conn, payload string) error {
 	}
 	return nil
 }
+
+// parseInt parses a command argument as a 64 bit integer, failing with the
+// error Redis replies with for anything else.
+func parseInt(arg []byte) (int64, error) {
+	n, ok := parseStrictInt(string(arg))
+	if !ok {
+		return 0, ErrNotInteger
+	}
+	return n, nil
+}
+
+// parseStrictInt only accepts the canonical form of an integer, without
+// signs, spaces or leading zeros, like Redis does when it decides whether a
+// string holds a number.
+func parseStrictInt(value string) (int64, bool) {
+	digits := strings.TrimPrefix(value, "-")
+	if len(digits) == 0 || (digits[0] == '0' && len(value) > 1) || digits[0] == '+' {
+		return 0, false
+	}
+
+	n, err := strconv.ParseInt(value, 10, 64)
+	return n, err == nil
+}
+
+// parseFloat parses a float the way INCRBYFLOAT and sorted set scores do. It
+// accepts inf but rejects NaN, values out of range and surrounding spaces.
+func parseFloat(value string) (float64, bool) {
+	if len(value) == 0 || strings.TrimSpace(value) != value {
+		return 0, false
+	}
+
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsNaN(f) {
+		return 0, false
+	}
+	return f, true
+}
+
+// absoluteExpiry turns the argument of an EX, PX, EXAT or PXAT option into a
+// unix time in milliseconds. It fails for times that are not positive or do
+// not fit in 64 bits.
+func absoluteExpiry(option string, n int64) (int64, error) {
+	if n <= 0 {
+		return 0, ErrNotInteger
+	}
+
+	switch strings.ToUpper(option) {
+	case "EX", "EXAT":
+		if n > math.MaxInt64/1000 {
+			return 0, ErrNotInteger
+		}
+		n *= 1000
+	}
+
+	switch strings.ToUpper(option) {
+	case "EX", "PX":
+		now := time.Now().UnixMilli()
+		if n > math.MaxInt64-now {
+			return 0, ErrNotInteger
+		}
+		n += now
+	}
+
+	return n, nil
+}
+
+// stringMatch reports whether str matches the glob-style pattern the way
+// Redis matches keys and channels: * and ? wildcards, [abc], [^abc] and
+// [a-z] classes, and backslash to escape a special character.
+func stringMatch(pattern string, str string, nocase bool) bool {
+	if nocase {
+		pattern, str = strings.ToLower(pattern), strings.ToLower(str)
+	}
+
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for len(pattern) > 1 && pattern[1] == '*' {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 1 {
+				return true
+			}
+			for i := 0; i <= len(str); i++ {
+				if stringMatch(pattern[1:], str[i:], false) {
+					return true
+				}
+			}
+			return false
+
+		case '?':
+			if len(str) == 0 {
+				return false
+			}
+			str = str[1:]
+
+		case '[':
+			if len(str) == 0 {
+				return false
+			}
+
+			pattern = pattern[1:]
+			not := len(pattern) > 0 && pattern[0] == '^'
+			if not {
+				pattern = pattern[1:]
+			}
+
+			match := false
+			for len(pattern) > 0 && pattern[0] != ']' {
+				switch {
+				case pattern[0] == '\\' && len(pattern) >= 2:
+					pattern = pattern[1:]
+					if pattern[0] == str[0] {
+						match = true
+					}
+				case len(pattern) >= 3 && pattern[1] == '-':
+					start, end := pattern[0], pattern[2]
+					if start > end {
+						start, end = end, start
+					}
+					if str[0] >= start && str[0] <= end {
+						match = true
+					}
+					pattern = pattern[2:]
+				default:
+					if pattern[0] == str[0] {
+						match = true
+					}
+				}
+				pattern = pattern[1:]
+			}
+
+			if not {
+				match = !match
+			}
+			if !match {
+				return false
+			}
+			str = str[1:]
+
+			// an unterminated class ends the pattern
+			if len(pattern) == 0 {
+				return len(str) == 0
+			}
+
+		case '\\':
+			if len(pattern) >= 2 {
+				pattern = pattern[1:]
+			}
+			fallthrough
+
+		default:
+			if len(str) == 0 || pattern[0] != str[0] {
+				return false
+			}
+			str = str[1:]
+		}
+
+		pattern = pattern[1:]
+	}
+
+	return len(str) == 0
+}
@@ -0,0 +1,157 @@
+package resp
+
+import (
+	"errors"
+
+	"nishojib/goredis/internal/types"
+)
+
+var errNotHLL = errors.New("WRONGTYPE Key is not a valid HyperLogLog string value.")
+var errCorruptHLL = errors.New("INVALIDOBJ Corrupted HLL object detected")
+
+// getHLL returns the HyperLogLog stored at key along with the string item
+// holding it, which the HyperLogLog updates in place. It fails when the key
+// holds anything but a HyperLogLog.
+func (rn *RESPNode) getHLL(key string) (*types.HLL, types.Item, bool, error) {
+	item, ok, err := rn.getString(key)
+	if err != nil || !ok {
+		return nil, item, false, err
+	}
+
+	hll, err := types.ParseHLL(item.Value)
+	if err != nil {
+		return nil, item, false, errNotHLL
+	}
+
+	return hll, item, true, nil
+}
+
+func (rn *RESPNode) handlePFAdd(c *client, args [][]byte) error {
+	key := string(args[0])
+
+	hll, item, ok, err := rn.getHLL(key)
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	if !ok {
+		hll = types.NewHLL()
+		item = types.Item{Type: "string"}
+	}
+
+	// creating the key counts as a change even without elements
+	changed := !ok
+	for _, element := range args[1:] {
+		added, err := hll.Add(element)
+		if err != nil {
+			c.w.WriteError(errCorruptHLL.Error())
+			return nil
+		}
+		changed = changed || added
+	}
+
+	if !changed {
+		c.w.WriteInteger(0)
+		return nil
+	}
+
+	// the registers may have outgrown the value they were updated in
+	item.Value = hll.Bytes()
+
+	rn.cache.Store(key, item)
+	c.dirty++
+
+	c.w.WriteInteger(1)
+	return nil
+}
+
+func (rn *RESPNode) handlePFCount(c *client, args [][]byte) error {
+	if len(args) == 1 {
+		key := string(args[0])
+
+		hll, _, ok, err := rn.getHLL(key)
+		if err != nil {
+			c.w.WriteError(err.Error())
+			return nil
+		}
+
+		if !ok {
+			c.w.WriteInteger(0)
+			return nil
+		}
+
+		// the count is cached in the stored value for the next time
+		count, err := hll.Count()
+		if err != nil {
+			c.w.WriteError(errCorruptHLL.Error())
+			return nil
+		}
+
+		c.w.WriteInteger(int64(count))
+		return nil
+	}
+
+	hlls := make([]*types.HLL, 0, len(args))
+	for _, key := range args {
+		hll, _, ok, err := rn.getHLL(string(key))
+		if err != nil {
+			c.w.WriteError(err.Error())
+			return nil
+		}
+
+		if ok {
+			hlls = append(hlls, hll)
+		}
+	}
+
+	count, err := types.CountUnion(hlls)
+	if err != nil {
+		c.w.WriteError(errCorruptHLL.Error())
+		return nil
+	}
+
+	c.w.WriteInteger(int64(count))
+	return nil
+}
+
+func (rn *RESPNode) handlePFMerge(c *client, args [][]byte) error {
+	destination := string(args[0])
+
+	union, item, ok, err := rn.getHLL(destination)
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	if !ok {
+		union = types.NewHLL()
+		item = types.Item{Type: "string"}
+	}
+
+	hlls := make([]*types.HLL, 0, len(args)-1)
+	for _, key := range args[1:] {
+		hll, _, ok, err := rn.getHLL(string(key))
+		if err != nil {
+			c.w.WriteError(err.Error())
+			return nil
+		}
+
+		if ok {
+			hlls = append(hlls, hll)
+		}
+	}
+
+	if err := union.Merge(hlls...); err != nil {
+		c.w.WriteError(errCorruptHLL.Error())
+		return nil
+	}
+
+	item.Value = union.Bytes()
+
+	rn.cache.Store(destination, item)
+	c.dirty++
+
+	c.w.WriteSimpleString("OK")
+	return nil
+}
@@ -0,0 +1,280 @@
+package resp
+
+import (
+	"bytes"
+	"math/rand/v2"
+	"strings"
+
+	"nishojib/goredis/internal/types"
+)
+
+// keyspace is what the generic key commands need from each of the stores
+// values live in.
+type keyspace interface {
+	Len() int
+	Keys() []string
+	RandomKey() (string, bool)
+	Delete(key string)
+}
+
+// keyspaces returns every store of the node, one per type of value.
+func (rn *RESPNode) keyspaces() []keyspace {
+	return []keyspace{
+		&rn.cache,
+		&rn.streamCache,
+		&rn.listCache,
+		&rn.hashCache,
+		&rn.setCache,
+		&rn.zsetCache,
+	}
+}
+
+// loadValue returns the value stored at key whatever its type.
+func (rn *RESPNode) loadValue(key string) (any, bool) {
+	var value any
+	var ok bool
+
+	switch rn.keyType(key) {
+	case "none":
+	case "stream":
+		value, ok = rn.streamCache.Load(key)
+	case "list":
+		value, ok = rn.listCache.Load(key)
+	case "hash":
+		value, ok = rn.hashCache.Load(key)
+	case "set":
+		value, ok = rn.setCache.Load(key)
+	case "zset":
+		value, ok = rn.zsetCache.Load(key)
+	default:
+		value, ok = rn.getItemFromStore(key)
+	}
+
+	return value, ok
+}
+
+// storeValue stores a value loaded by loadValue at key, replacing whatever
+// the key held before.
+func (rn *RESPNode) storeValue(key string, value any) {
+	rn.deleteKey(key)
+
+	switch v := value.(type) {
+	case types.Item:
+		rn.cache.Store(key, v)
+	case *types.Stream:
+		rn.streamCache.Store(key, v)
+		rn.streamReady.Broadcast()
+	case *types.List:
+		rn.listCache.Store(key, v)
+	case types.Hash:
+		rn.hashCache.Store(key, v)
+	case types.Set:
+		rn.setCache.Store(key, v)
+	case *types.ZSet:
+		rn.zsetCache.Store(key, v)
+	}
+}
+
+// cloneValue returns a copy of a value loaded by loadValue that shares
+// nothing the original may modify.
+func cloneValue(value any) any {
+	switch v := value.(type) {
+	case types.Item:
+		v.Value = bytes.Clone(v.Value)
+		return v
+	case *types.Stream:
+		return v.Clone()
+	case *types.List:
+		return v.Clone()
+	case types.Hash:
+		return v.Clone()
+	case types.Set:
+		return v.Clone()
+	case *types.ZSet:
+		return v.Clone()
+	}
+	return value
+}
+
+func (rn *RESPNode) handleDel(c *client, args [][]byte) error {
+	deleted := 0
+	for _, key := range args {
+		if rn.deleteKey(string(key)) {
+			deleted++
+		}
+	}
+
+	if deleted > 0 {
+		c.dirty++
+	}
+
+	c.w.WriteInteger(int64(deleted))
+	return nil
+}
+
+// handleUnlink is DEL. Redis frees large values in a background thread so
+// that UNLINK does not block, which deleting the keys already does here: once
+// they are out of the stores, nothing refers to their values and the garbage
+// collector reclaims them concurrently.
+func (rn *RESPNode) handleUnlink(c *client, args [][]byte) error {
+	return rn.handleDel(c, args)
+}
+
+func (rn *RESPNode) handleExists(c *client, args [][]byte) error {
+	count := 0
+	for _, key := range args {
+		if rn.keyType(string(key)) != "none" {
+			count++
+		}
+	}
+
+	c.w.WriteInteger(int64(count))
+	return nil
+}
+
+func (rn *RESPNode) handleTouch(c *client, args [][]byte) error {
+	return rn.handleExists(c, args)
+}
+
+func (rn *RESPNode) handleRename(c *client, args [][]byte) error {
+	return rn.rename(c, string(args[0]), string(args[1]), false)
+}
+
+func (rn *RESPNode) handleRenameNX(c *client, args [][]byte) error {
+	return rn.rename(c, string(args[0]), string(args[1]), true)
+}
+
+// rename moves the value at key to newKey, unless newKey exists and nx is
+// set, in which case it replies with 0.
+func (rn *RESPNode) rename(c *client, key string, newKey string, nx bool) error {
+	value, ok := rn.loadValue(key)
+	if !ok {
+		c.w.WriteError("ERR no such key")
+		return nil
+	}
+
+	if nx && rn.keyType(newKey) != "none" {
+		c.w.WriteInteger(0)
+		return nil
+	}
+
+	if key != newKey {
+		expiry := rn.expiryOf(key)
+
+		rn.deleteKey(key)
+		rn.storeValue(newKey, value)
+		if expiry != -1 {
+			rn.setExpiry(newKey, expiry)
+		}
+		c.dirty++
+	}
+
+	if nx {
+		if key == newKey {
+			c.w.WriteInteger(0)
+		} else {
+			c.w.WriteInteger(1)
+		}
+		return nil
+	}
+
+	c.w.WriteSimpleString("OK")
+	return nil
+}
+
+func (rn *RESPNode) handleCopy(c *client, args [][]byte) error {
+	source, destination := string(args[0]), string(args[1])
+
+	replace := false
+	for i := 2; i < len(args); i++ {
+		switch option := strings.ToUpper(string(args[i])); {
+		case option == "REPLACE":
+			replace = true
+		case option == "DB" && i+1 < len(args):
+			db, err := parseInt(args[i+1])
+			if err != nil {
+				c.w.WriteError(err.Error())
+				return nil
+			}
+			if db != 0 {
+				c.w.WriteError("ERR DB index is out of range")
+				return nil
+			}
+			i++
+		default:
+			c.w.WriteError(ErrSyntax.Error())
+			return nil
+		}
+	}
+
+	if source == destination {
+		c.w.WriteError("ERR source and destination objects are the same")
+		return nil
+	}
+
+	value, ok := rn.loadValue(source)
+	if !ok {
+		c.w.WriteInteger(0)
+		return nil
+	}
+
+	if !replace && rn.keyType(destination) != "none" {
+		c.w.WriteInteger(0)
+		return nil
+	}
+
+	rn.storeValue(destination, cloneValue(value))
+	if expiry := rn.expiryOf(source); expiry != -1 {
+		rn.setExpiry(destination, expiry)
+	}
+	c.dirty++
+
+	c.w.WriteInteger(1)
+	return nil
+}
+
+func (rn *RESPNode) handleRandomKey(c *client, args [][]byte) error {
+	keyspaces := rn.keyspaces()
+
+	// expired keys linger until something looks them up, so a few picks
+	// may miss
+	for range 100 {
+		total := 0
+		for _, ks := range keyspaces {
+			total += ks.Len()
+		}
+
+		if total == 0 {
+			break
+		}
+
+		// pick a store with odds in proportion to its size, then a key in it
+		n := rand.IntN(total)
+		for _, ks := range keyspaces {
+			if n >= ks.Len() {
+				n -= ks.Len()
+				continue
+			}
+
+			key, ok := ks.RandomKey()
+			if ok && rn.keyType(key) != "none" {
+				c.w.WriteBulkString(key)
+				return nil
+			}
+			break
+		}
+	}
+
+	c.w.WriteNull()
+	return nil
+}
+
+func (rn *RESPNode) handleDBSize(c *client, args [][]byte) error {
+	size := 0
+	for _, ks := range rn.keyspaces() {
+		size += ks.Len()
+	}
+
+	c.w.WriteInteger(int64(size))
+	return nil
+}
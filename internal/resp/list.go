@@ -0,0 +1,487 @@
+package resp
+
+import (
+	"strings"
+
+	"nishojib/goredis/internal/types"
+)
+
+// getList returns the list stored at key. It fails with ErrWrongType when
+// the key holds a value of another type.
+func (rn *RESPNode) getList(key string) (*types.List, bool, error) {
+	keyType := rn.keyType(key)
+	if keyType == "none" {
+		return nil, false, nil
+	}
+	if keyType != "list" {
+		return nil, false, ErrWrongType
+	}
+
+	list, ok := rn.listCache.Load(key)
+	return list, ok, nil
+}
+
+// deleteIfEmptyList removes key once its list has no elements left, since
+// Redis never keeps empty lists around.
+func (rn *RESPNode) deleteIfEmptyList(key string, list *types.List) {
+	if list.Len() == 0 {
+		rn.deleteKey(key)
+	}
+}
+
+func (rn *RESPNode) handleLPush(c *client, args [][]byte) error {
+	return rn.push(c, args, true)
+}
+
+func (rn *RESPNode) handleRPush(c *client, args [][]byte) error {
+	return rn.push(c, args, false)
+}
+
+func (rn *RESPNode) push(c *client, args [][]byte, left bool) error {
+	key := string(args[0])
+
+	list, ok, err := rn.getList(key)
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	if !ok {
+		list = types.NewList()
+		rn.listCache.Store(key, list)
+	}
+
+	values := make([]string, 0, len(args)-1)
+	for _, arg := range args[1:] {
+		values = append(values, string(arg))
+	}
+
+	if left {
+		list.PushLeft(values...)
+	} else {
+		list.PushRight(values...)
+	}
+	c.dirty++
+
+	c.w.WriteInteger(int64(list.Len()))
+	return nil
+}
+
+func (rn *RESPNode) handleLPop(c *client, args [][]byte) error {
+	return rn.pop(c, args, true)
+}
+
+func (rn *RESPNode) handleRPop(c *client, args [][]byte) error {
+	return rn.pop(c, args, false)
+}
+
+func (rn *RESPNode) pop(c *client, args [][]byte, left bool) error {
+	key := string(args[0])
+
+	if len(args) > 2 {
+		c.w.WriteError(ErrSyntax.Error())
+		return nil
+	}
+
+	count := int64(-1)
+	if len(args) == 2 {
+		n, err := parseInt(args[1])
+		if err != nil || n < 0 {
+			c.w.WriteError("ERR value is out of range, must be positive")
+			return nil
+		}
+		count = n
+	}
+
+	list, ok, err := rn.getList(key)
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	if !ok {
+		if count == -1 {
+			c.w.WriteNull()
+		} else {
+			c.w.WriteNullArray()
+		}
+		return nil
+	}
+
+	popOne := list.PopRight
+	if left {
+		popOne = list.PopLeft
+	}
+
+	if count == -1 {
+		value, _ := popOne()
+		rn.deleteIfEmptyList(key, list)
+		c.dirty++
+
+		c.w.WriteBulkString(value)
+		return nil
+	}
+
+	values := []string{}
+	for int64(len(values)) < count {
+		value, ok := popOne()
+		if !ok {
+			break
+		}
+		values = append(values, value)
+	}
+
+	rn.deleteIfEmptyList(key, list)
+	if len(values) > 0 {
+		c.dirty++
+	}
+
+	c.w.WriteBulkStrings(values)
+	return nil
+}
+
+func (rn *RESPNode) handleLLen(c *client, args [][]byte) error {
+	list, ok, err := rn.getList(string(args[0]))
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	if !ok {
+		c.w.WriteInteger(0)
+		return nil
+	}
+
+	c.w.WriteInteger(int64(list.Len()))
+	return nil
+}
+
+func (rn *RESPNode) handleLRange(c *client, args [][]byte) error {
+	start, err := parseInt(args[1])
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	end, err := parseInt(args[2])
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	list, ok, err := rn.getList(string(args[0]))
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	if !ok {
+		c.w.WriteArray(0)
+		return nil
+	}
+
+	c.w.WriteBulkStrings(list.Range(int(start), int(end)))
+	return nil
+}
+
+func (rn *RESPNode) handleLIndex(c *client, args [][]byte) error {
+	index, err := parseInt(args[1])
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	list, ok, err := rn.getList(string(args[0]))
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	if !ok {
+		c.w.WriteNull()
+		return nil
+	}
+
+	value, ok := list.Index(int(index))
+	if !ok {
+		c.w.WriteNull()
+		return nil
+	}
+
+	c.w.WriteBulkString(value)
+	return nil
+}
+
+func (rn *RESPNode) handleLSet(c *client, args [][]byte) error {
+	index, err := parseInt(args[1])
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	list, ok, err := rn.getList(string(args[0]))
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	if !ok {
+		c.w.WriteError("ERR no such key")
+		return nil
+	}
+
+	if !list.Set(int(index), string(args[2])) {
+		c.w.WriteError("ERR index out of range")
+		return nil
+	}
+	c.dirty++
+
+	c.w.WriteSimpleString("OK")
+	return nil
+}
+
+func (rn *RESPNode) handleLRem(c *client, args [][]byte) error {
+	key := string(args[0])
+
+	count, err := parseInt(args[1])
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	list, ok, err := rn.getList(key)
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	if !ok {
+		c.w.WriteInteger(0)
+		return nil
+	}
+
+	removed := list.Remove(int(count), string(args[2]))
+	if removed > 0 {
+		rn.deleteIfEmptyList(key, list)
+		c.dirty++
+	}
+
+	c.w.WriteInteger(int64(removed))
+	return nil
+}
+
+func (rn *RESPNode) handleLTrim(c *client, args [][]byte) error {
+	key := string(args[0])
+
+	start, err := parseInt(args[1])
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	end, err := parseInt(args[2])
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	list, ok, err := rn.getList(key)
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	if ok {
+		list.Trim(int(start), int(end))
+		rn.deleteIfEmptyList(key, list)
+		c.dirty++
+	}
+
+	c.w.WriteSimpleString("OK")
+	return nil
+}
+
+func (rn *RESPNode) handleLInsert(c *client, args [][]byte) error {
+	var before bool
+	switch strings.ToUpper(string(args[1])) {
+	case "BEFORE":
+		before = true
+	case "AFTER":
+		before = false
+	default:
+		c.w.WriteError(ErrSyntax.Error())
+		return nil
+	}
+
+	list, ok, err := rn.getList(string(args[0]))
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	if !ok {
+		c.w.WriteInteger(0)
+		return nil
+	}
+
+	if !list.Insert(string(args[2]), string(args[3]), before) {
+		c.w.WriteInteger(-1)
+		return nil
+	}
+	c.dirty++
+
+	c.w.WriteInteger(int64(list.Len()))
+	return nil
+}
+
+func (rn *RESPNode) handleLPos(c *client, args [][]byte) error {
+	element := string(args[1])
+
+	rank, count, maxLen := int64(1), int64(-1), int64(0)
+
+	for i := 2; i < len(args); i += 2 {
+		if i+1 >= len(args) {
+			c.w.WriteError(ErrSyntax.Error())
+			return nil
+		}
+
+		n, err := parseInt(args[i+1])
+		if err != nil {
+			c.w.WriteError(err.Error())
+			return nil
+		}
+
+		switch strings.ToUpper(string(args[i])) {
+		case "RANK":
+			if n == 0 {
+				c.w.WriteError(
+					"ERR RANK can't be zero: use 1 to start from the first match, " +
+						"2 from the second ... or use negative to start from the end of the list",
+				)
+				return nil
+			}
+			rank = n
+		case "COUNT":
+			if n < 0 {
+				c.w.WriteError("ERR COUNT can't be negative")
+				return nil
+			}
+			count = n
+		case "MAXLEN":
+			if n < 0 {
+				c.w.WriteError("ERR MAXLEN can't be negative")
+				return nil
+			}
+			maxLen = n
+		default:
+			c.w.WriteError(ErrSyntax.Error())
+			return nil
+		}
+	}
+
+	list, ok, err := rn.getList(string(args[0]))
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	matches := []int64{}
+	if ok {
+		values := list.Values()
+		skip := max(rank, -rank) - 1
+
+		for n := 0; n < len(values) && (maxLen == 0 || int64(n) < maxLen); n++ {
+			i := n
+			if rank < 0 {
+				i = len(values) - 1 - n
+			}
+
+			if values[i] != element {
+				continue
+			}
+
+			if skip > 0 {
+				skip--
+				continue
+			}
+
+			matches = append(matches, int64(i))
+			if count != 0 && int64(len(matches)) >= max(count, 1) {
+				break
+			}
+		}
+	}
+
+	if count == -1 {
+		if len(matches) == 0 {
+			c.w.WriteNull()
+			return nil
+		}
+		c.w.WriteInteger(matches[0])
+		return nil
+	}
+
+	c.w.WriteArray(len(matches))
+	for _, i := range matches {
+		c.w.WriteInteger(i)
+	}
+	return nil
+}
+
+func (rn *RESPNode) handleLMove(c *client, args [][]byte) error {
+	source, destination := string(args[0]), string(args[1])
+
+	sides := [2]bool{}
+	for i, arg := range args[2:] {
+		switch strings.ToUpper(string(arg)) {
+		case "LEFT":
+			sides[i] = true
+		case "RIGHT":
+			sides[i] = false
+		default:
+			c.w.WriteError(ErrSyntax.Error())
+			return nil
+		}
+	}
+
+	src, ok, err := rn.getList(source)
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	if !ok {
+		c.w.WriteNull()
+		return nil
+	}
+
+	dst, dstOk, err := rn.getList(destination)
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	var value string
+	if sides[0] {
+		value, _ = src.PopLeft()
+	} else {
+		value, _ = src.PopRight()
+	}
+
+	if !dstOk {
+		dst = types.NewList()
+		rn.listCache.Store(destination, dst)
+	}
+
+	if sides[1] {
+		dst.PushLeft(value)
+	} else {
+		dst.PushRight(value)
+	}
+
+	rn.deleteIfEmptyList(source, src)
+	c.dirty++
+
+	c.w.WriteBulkString(value)
+	return nil
+}
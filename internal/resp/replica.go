@@ -0,0 +1,93 @@
+package resp
+
+import (
+	"fmt"
+	"net"
+	"sync"
+)
+
+// replicaOutputLimit is how much a replica may fall behind on propagated
+// commands before it is dropped, as client-output-buffer-limit defaults to
+// for replicas in Redis.
+const replicaOutputLimit = 256 << 20
+
+// replica is the connection of one of our replicas. Commands propagated to it
+// are queued in order while the keyspace lock is held, and written by a
+// goroutine of its own so that a slow replica never holds up the others or
+// any client.
+type replica struct {
+	conn  net.Conn
+	ready chan struct{}
+
+	mu      sync.Mutex
+	pending []byte
+	failed  bool
+}
+
+func newReplica(conn net.Conn) *replica {
+	r := &replica{conn: conn, ready: make(chan struct{}, 1)}
+	go r.drain()
+	return r
+}
+
+// send queues payload for the replica. It returns false once the replica
+// failed, or fell too far behind and was dropped.
+func (r *replica) send(payload string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.failed {
+		return false
+	}
+
+	if len(r.pending)+len(payload) > replicaOutputLimit {
+		r.failed = true
+		r.conn.Close()
+		return false
+	}
+
+	r.pending = append(r.pending, payload...)
+
+	select {
+	case r.ready <- struct{}{}:
+	default:
+	}
+	return true
+}
+
+// drain writes whatever is queued until a write fails, which closes the
+// connection.
+func (r *replica) drain() {
+	var buf []byte
+
+	for range r.ready {
+		r.mu.Lock()
+		buf, r.pending = r.pending, buf[:0]
+		r.mu.Unlock()
+
+		if _, err := r.conn.Write(buf); err != nil {
+			r.mu.Lock()
+			r.failed = true
+			r.mu.Unlock()
+
+			r.conn.Close()
+			return
+		}
+	}
+}
+
+// propToSlaves queues payload for every replica, dropping those that failed
+// so that the rest still get it.
+func (rn *RESPNode) propToSlaves(payload string) {
+	replicas := rn.SlaveConns.conns[:0]
+	for _, r := range rn.SlaveConns.conns {
+		if !r.send(payload) {
+			fmt.Println("dropped a replica that failed:", r.conn.RemoteAddr())
+			continue
+		}
+		replicas = append(replicas, r)
+	}
+
+	clear(rn.SlaveConns.conns[len(replicas):])
+	rn.SlaveConns.conns = replicas
+}
@@ -1,192 +1,85 @@
 package resp
 
 import (
-	"bytes"
-	"encoding/base64"
 	"fmt"
-	"net"
-	"strconv"
-	"time"
+	"strings"
 
 	"nishojib/goredis/internal/parser"
 	"nishojib/goredis/internal/types"
 )
 
-const (
-	PING     = "ping"
-	ECHO     = "echo"
-	SET      = "set"
-	GET      = "get"
-	INFO     = "info"
-	REPLCONF = "replconf"
-	PSYNC    = "psync"
-	WAIT     = "wait"
-	CONFIG   = "config"
-	KEYS     = "keys"
-	TYPE     = "type"
-	XADD     = "xadd"
-)
-
-func (rn *RESPNode) parseRequest(query []byte) ([]types.Command, error) {
-	var commands []types.Command
-
-	offset := 0
-	qLength := len(query)
-
-	for offset < qLength {
-		firstEl := query[offset]
-
-		switch firstEl {
-		case '*':
-			firstIndex := offset
-			tks := bytes.SplitN(query[firstIndex:], []byte("\r\n"), 2)
-			count, err := strconv.Atoi(string(tks[0][1:]))
-			if err != nil {
-				fmt.Printf(
-					"wrong request. error converting to int in func parseRequest: %#v\n",
-					string(tks[0][1:]),
-				)
-				continue
-			}
-			tokens := bytes.SplitN(tks[1], []byte("\r\n"), count*2+1)
-			offset = qLength - len(tokens[count*2])
-
-			name, args, err := parser.DecodeArray(
-				bytes.Split(query[firstIndex:offset], []byte("\r\n")),
-			)
-			if err != nil {
-				return []types.Command{}, err
-			}
-
-			n := len(query[firstIndex:offset])
-
-			if rn.IsSlave {
-				rn.bytesProc.mutex.Lock()
-				rn.bytesProc.all += n
-				rn.bytesProc.currReq = n
-				rn.bytesProc.mutex.Unlock()
-			}
-
-			commands = append(commands, types.Command{Name: name, Args: args})
-		case '$':
-			firstIndex := offset
-			tks := bytes.SplitN(query[firstIndex:], []byte("\r\n"), 2)
-			count, err := strconv.Atoi(string(tks[0][1:]))
-			if err != nil {
-				fmt.Printf(
-					"wrong request. error converting to int in func parseRequest: %#v\n",
-					string(tks[0][1:]),
-				)
-				continue
-			}
-
-			pos := offset + len(tks[0]) + len([]byte("\r\n"))
-			sl := query[pos : pos+count]
-			binData, _ := base64.StdEncoding.DecodeString(emptyRDB)
-			if bytes.Equal(sl, binData) {
-				fmt.Println("RDB File...")
-
-				rn.handshakeDone = true
-				rn.bytesProc.mutex.Lock()
-				rn.bytesProc.all = 0
-				rn.bytesProc.currReq = 0
-				rn.bytesProc.mutex.Unlock()
-			}
-
-			offset = pos + count
-
-		default:
-			offset++
-		}
+// readCommand reads the next request from the connection. Empty requests,
+// such as a blank line typed into telnet, yield a command without a name.
+func (rn *RESPNode) readCommand(reader *parser.Reader) (types.Command, error) {
+	value, n, err := reader.ReadRequest()
+	if err != nil {
+		return types.Command{}, err
 	}
 
-	return commands, nil
-}
-
-func (rn *RESPNode) processRequest(conn net.Conn, command types.Command) error {
-	args := command.Args
-
-	switch command.Name {
-	case PING:
-		if rn.IsSlave && rn.handshakeDone {
-			return nil
-		}
-		return rn.handlePing(conn)
-
-	case ECHO:
-		if rn.IsSlave && rn.handshakeDone {
-			return nil
-		}
-		return rn.handleEcho(conn, string(args[0]))
-
-	case SET:
-		var expMillSec int64 = -1
-		if len(args) > 2 {
-			expMills, err := strconv.Atoi(string(args[3]))
-			if err != nil {
-				return err
-			}
-
-			expMillSec = int64(expMills)
-		}
-
-		return rn.handleSet(conn, string(args[0]), string(args[1]), expMillSec)
-
-	case GET:
-		return rn.handleGet(conn, string(args[0]))
+	if value.Type != '*' || len(value.Array) == 0 {
+		return types.NewCommand("", nil, n), nil
+	}
 
-	case INFO:
-		arg := ""
-		if len(args) > 0 {
-			arg = string(args[0])
+	args := make([][]byte, 0, len(value.Array))
+	for _, el := range value.Array {
+		if el.Type != '$' || el.Null {
+			return types.Command{}, fmt.Errorf(
+				"%w: expected '$', got '%c'",
+				parser.ErrProtocol,
+				el.Type,
+			)
 		}
 
-		return rn.handleInfo(conn, arg)
-
-	case REPLCONF:
-		return rn.handleReplconf(conn, string(args[0]))
+		args = append(args, el.Str)
+	}
 
-	case PSYNC:
-		if rn.IsSlave && rn.handshakeDone {
-			return nil
-		}
-		return rn.handlePsync(conn)
+	return types.NewCommand(strings.ToLower(string(args[0])), args[1:], n), nil
+}
 
-	case WAIT:
-		numReplicas, err := strconv.ParseInt(string(args[0]), 10, 64)
-		if err != nil {
-			return err
-		}
+func (rn *RESPNode) processRequest(c *client, command types.Command) error {
+	cmd, ok := commandTable[command.Name]
+	if !ok {
+		return rn.handleUnknown(c, command)
+	}
 
-		timeout, err := strconv.ParseInt(string(args[1]), 10, 64)
-		if err != nil {
-			return err
-		}
+	if !cmd.checkArity(command.Args) {
+		c.w.WriteError(fmt.Sprintf("ERR wrong number of arguments for '%s' command", cmd.name))
+		return nil
+	}
 
-		return rn.handleWait(conn, numReplicas, timeout)
+	rn.mu.Lock()
+	defer rn.mu.Unlock()
 
-	case CONFIG:
-		return rn.handleConfig(conn, string(args[1]))
+	c.dirty = 0
+	c.repl = nil
+	c.cmd = cmd
+	rn.current = c
 
-	case KEYS:
-		return rn.handleKeys(conn, args[0])
+	err := cmd.handler(rn, c, command.Args)
+	if err != nil {
+		return err
+	}
 
-	case TYPE:
-		return rn.handleType(conn, string(args[0]))
+	// queueing under the lock keeps replicas applying writes in the order
+	// they were executed
+	if cmd.flags&flagWrite != 0 && c.dirty > 0 && !rn.IsSlave {
+		rn.propagate(c, command)
+	}
 
-	case XADD:
-		return rn.handleXAdd(conn, args)
+	return nil
+}
 
-	default:
-		if rn.IsSlave && rn.handshakeDone {
-			return nil
+func (rn *RESPNode) propagate(c *client, command types.Command) {
+	commands := c.repl
+	if commands == nil {
+		args := []string{command.Name}
+		for _, arg := range command.Args {
+			args = append(args, string(arg))
 		}
-		return rn.handleUnknown(conn)
+		commands = [][]string{args}
 	}
-}
 
-func (rn *RESPNode) removeKeyAfter(key string, expMillSec int64) {
-	timer := time.NewTimer(time.Duration(expMillSec) * time.Millisecond)
-	<-timer.C
-	rn.cache.Delete(key)
+	for _, args := range commands {
+		rn.propToSlaves(parser.EncodeArray(args))
+	}
 }
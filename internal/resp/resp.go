@@ -6,6 +6,7 @@ import (
 	"io"
 	"net"
 	"sync"
+	"sync/atomic"
 
 	"nishojib/goredis/internal/parser"
 	"nishojib/goredis/internal/rdb"
@@ -19,11 +20,32 @@ type RESPNode struct {
 	IsSlave          bool
 	Role             string
 	SlaveConns       *Connections
-	handshakeDone    bool
 	bytesProc        bytesProcessed
 	RDBFile          RDBFile
 	cache            store.Store[types.Item]
-	streamCache      store.Store[types.Stream]
+	streamCache      store.Store[*types.Stream]
+	listCache        store.Store[*types.List]
+	hashCache        store.Store[types.Hash]
+	setCache         store.Store[types.Set]
+	zsetCache        store.Store[*types.ZSet]
+	nextClientID     atomic.Int64
+
+	// expires holds the unix time in milliseconds each key with a time to
+	// live expires at, whatever the type of its value
+	expires     store.Store[int64]
+	expireStats expireStats
+
+	// current is the client whose command is running, which decides how a
+	// replica looks up expired keys
+	current *client
+
+	// mu is held while a command runs, so every command sees and leaves the
+	// keyspace in a consistent state the way it would in Redis
+	mu sync.Mutex
+
+	// streamReady is broadcast whenever an entry is added to a stream, waking
+	// blocked XREAD calls so they can look for it
+	streamReady *sync.Cond
 }
 
 type RDBFile struct {
@@ -42,7 +64,7 @@ type Connections struct {
 	numWait     int
 	numAck      int
 	waitChannel chan bool
-	conns       []net.Conn
+	conns       []*replica
 	mutex       sync.Mutex
 }
 
@@ -52,7 +74,7 @@ func New(
 	role string,
 	rdbFile RDBFile,
 ) *RESPNode {
-	return &RESPNode{
+	rn := &RESPNode{
 		MasterReplID:     masterReplID,
 		MasterReplOffset: masterReplOffset,
 		IsSlave:          role == "slave",
@@ -61,13 +83,21 @@ func New(
 			numWait:     0,
 			numAck:      0,
 			waitChannel: make(chan bool, 1),
-			conns:       make([]net.Conn, 0),
+			conns:       make([]*replica, 0),
 		},
 		Role:        role,
 		RDBFile:     rdbFile,
 		cache:       store.New[types.Item](),
-		streamCache: store.New[types.Stream](),
+		streamCache: store.New[*types.Stream](),
+		listCache:   store.New[*types.List](),
+		hashCache:   store.New[types.Hash](),
+		setCache:    store.New[types.Set](),
+		zsetCache:   store.New[*types.ZSet](),
+		expires:     store.New[int64](),
 	}
+	rn.streamReady = sync.NewCond(&rn.mu)
+
+	return rn
 }
 
 func (rn *RESPNode) ConnectToMaster(masterHost string, masterPort string) {
@@ -77,90 +107,90 @@ func (rn *RESPNode) ConnectToMaster(masterHost string, masterPort string) {
 		return
 	}
 
-	err = sendResponse(conn, parser.EncodeArray([]string{"ping"}))
-	if err != nil {
-		fmt.Printf("error sending data to the master node: %s", err.Error())
-		return
-	}
+	reader := parser.NewReader(conn)
 
-	buf := make([]byte, 1024)
-	n, err := conn.Read(buf)
-	if err != nil {
-		fmt.Printf("error receiving data from the master node: %s", err.Error())
-		return
+	handshake := [][]string{
+		{"ping"},
+		{"REPLCONF", "listening-port", "6380"},
+		{"REPLCONF", "capa", "psync2"},
+		{"PSYNC", "?", "-1"},
 	}
-	fmt.Printf("received from master: %#v\n", string(buf[:n]))
 
-	err = sendResponse(conn, parser.EncodeArray([]string{"REPLCONF", "listening-port", "6380"}))
-	if err != nil {
-		fmt.Printf(
-			"error sending REPLCONF command (listening-port) to the master node %s",
-			err.Error(),
-		)
-		return
-	}
-	n, err = conn.Read(buf)
-	if err != nil {
-		fmt.Printf("error receiving data from the master node: %s", err.Error())
-		return
-	}
-	fmt.Printf("received from master: %#v\n", string(buf[:n]))
+	for _, command := range handshake {
+		err = sendResponse(conn, parser.EncodeArray(command))
+		if err != nil {
+			fmt.Printf("error sending %s command to the master node: %s", command[0], err.Error())
+			conn.Close()
+			return
+		}
 
-	err = sendResponse(conn, parser.EncodeArray([]string{"REPLCONF", "capa", "psync2"}))
-	if err != nil {
-		fmt.Printf(
-			"error sending REPLCONF command (capa) to the master node %s",
-			err.Error(),
-		)
-		return
-	}
-	n, err = conn.Read(buf)
-	if err != nil {
-		fmt.Printf("error receiving data from the master node: %s", err.Error())
-		return
+		reply, _, err := reader.ReadValue()
+		if err != nil {
+			fmt.Printf("error receiving data from the master node: %s", err.Error())
+			conn.Close()
+			return
+		}
+		fmt.Printf("received from master: %#v\n", string(reply.Str))
 	}
-	fmt.Printf("received from master: %#v\n", string(buf[:n]))
 
-	err = sendResponse(conn, parser.EncodeArray([]string{"PSYNC", "?", "-1"}))
+	_, err = reader.ReadRDB()
 	if err != nil {
-		fmt.Printf("error sending PSYNC command to the master node %s", err.Error())
+		fmt.Printf("error receiving the RDB file from the master node: %s", err.Error())
+		conn.Close()
 		return
 	}
+	fmt.Println("RDB File...")
+
+	rn.bytesProc.mutex.Lock()
+	rn.bytesProc.all = 0
+	rn.bytesProc.currReq = 0
+	rn.bytesProc.mutex.Unlock()
 
-	go rn.HandleClient(conn)
+	go rn.serve(rn.newMasterClient(conn, reader))
 }
 
 func (rn *RESPNode) HandleClient(conn net.Conn) {
-	defer conn.Close()
+	rn.serve(rn.newClient(conn, parser.NewReader(conn)))
+}
+
+func (rn *RESPNode) serve(c *client) {
+	defer c.conn.Close()
 
 	for {
-		buf := make([]byte, 1024)
-		n, err := conn.Read(buf)
+		command, err := rn.readCommand(c.reader)
 		if err != nil {
-			if errors.Is(err, io.EOF) {
-				continue
+			if errors.Is(err, parser.ErrProtocol) {
+				c.w.WriteError("ERR " + err.Error())
 			}
-			fmt.Printf("error handling connection: %s", err.Error())
-			return
-		}
+			c.w.Flush()
 
-		fmt.Printf("query: %#v\n", string(buf[:n]))
-
-		commands, err := rn.parseRequest(buf[:n])
-		if err != nil {
-			fmt.Printf("error parsing request: %s", err.Error())
+			if !errors.Is(err, io.EOF) {
+				fmt.Printf("error reading request: %s\n", err.Error())
+			}
 			return
 		}
 
-		for _, command := range commands {
-			if command.Name == "" {
-				fmt.Println("Empty command name")
-				continue
+		if command.Name != "" {
+			if c.master {
+				rn.bytesProc.mutex.Lock()
+				rn.bytesProc.all += command.Length
+				rn.bytesProc.currReq = command.Length
+				rn.bytesProc.mutex.Unlock()
 			}
 
-			err = rn.processRequest(conn, command)
+			err = rn.processRequest(c, command)
 			if err != nil {
-				fmt.Printf("error processing request: %s", err.Error())
+				fmt.Printf("error processing request: %s\n", err.Error())
+				c.w.Flush()
+				return
+			}
+		}
+
+		// replies to pipelined commands are sent together once every
+		// command that was already received has been processed
+		if c.reader.Buffered() == 0 {
+			if err := c.w.Flush(); err != nil {
+				fmt.Printf("error sending response: %s\n", err.Error())
 				return
 			}
 		}
@@ -175,23 +205,9 @@ func (rn *RESPNode) Restore() error {
 	fmt.Println("len of values: ", len(values))
 
 	for _, el := range values {
-		if el.Item.Expiry != -1 {
-			go rn.removeKeyAfter(el.Name, el.Item.Expiry)
-		}
-
-		rn.cache.Store(el.Name, el.Item)
-		fmt.Println("key", el.Name, "val", el.Item.Value)
+		rn.setString(el.Name, el.Item, el.Expiry)
+		fmt.Println("key", el.Name, "val", string(el.Item.Value))
 	}
 
 	return nil
 }
-
-func (rn *RESPNode) propToSlaves(payload string) error {
-	for _, slaveConn := range rn.SlaveConns.conns {
-		err := sendResponse(slaveConn, payload)
-		if err != nil {
-			return err
-		}
-	}
-	return nil
-}
@@ -0,0 +1,436 @@
+package resp
+
+import (
+	"math"
+	"strings"
+
+	"nishojib/goredis/internal/types"
+)
+
+// getSet returns the set stored at key. It fails with ErrWrongType when the
+// key holds a value of another type.
+func (rn *RESPNode) getSet(key string) (types.Set, bool, error) {
+	keyType := rn.keyType(key)
+	if keyType == "none" {
+		return nil, false, nil
+	}
+	if keyType != "set" {
+		return nil, false, ErrWrongType
+	}
+
+	set, ok := rn.setCache.Load(key)
+	return set, ok, nil
+}
+
+// getSets returns the sets stored at keys, with missing keys read as empty
+// sets.
+func (rn *RESPNode) getSets(keys [][]byte) ([]types.Set, error) {
+	sets := make([]types.Set, 0, len(keys))
+	for _, key := range keys {
+		set, _, err := rn.getSet(string(key))
+		if err != nil {
+			return nil, err
+		}
+		sets = append(sets, set)
+	}
+	return sets, nil
+}
+
+// storeSet replaces whatever destination held with set, or deletes it when
+// set is empty.
+func (rn *RESPNode) storeSet(destination string, set types.Set) {
+	rn.deleteKey(destination)
+	if len(set) > 0 {
+		rn.setCache.Store(destination, set)
+	}
+}
+
+func writeSet(c *client, set types.Set) {
+	c.w.WriteSet(len(set))
+	for member := range set {
+		c.w.WriteBulkString(member)
+	}
+}
+
+func (rn *RESPNode) handleSAdd(c *client, args [][]byte) error {
+	key := string(args[0])
+
+	set, ok, err := rn.getSet(key)
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	if !ok {
+		set = types.NewSet()
+		rn.setCache.Store(key, set)
+	}
+
+	added := 0
+	for _, member := range args[1:] {
+		if _, ok := set[string(member)]; !ok {
+			set[string(member)] = struct{}{}
+			added++
+		}
+	}
+
+	if added > 0 {
+		c.dirty++
+	}
+
+	c.w.WriteInteger(int64(added))
+	return nil
+}
+
+func (rn *RESPNode) handleSRem(c *client, args [][]byte) error {
+	key := string(args[0])
+
+	set, ok, err := rn.getSet(key)
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	if !ok {
+		c.w.WriteInteger(0)
+		return nil
+	}
+
+	removed := 0
+	for _, member := range args[1:] {
+		if _, ok := set[string(member)]; ok {
+			delete(set, string(member))
+			removed++
+		}
+	}
+
+	if len(set) == 0 {
+		rn.deleteKey(key)
+	}
+
+	if removed > 0 {
+		c.dirty++
+	}
+
+	c.w.WriteInteger(int64(removed))
+	return nil
+}
+
+func (rn *RESPNode) handleSMembers(c *client, args [][]byte) error {
+	set, _, err := rn.getSet(string(args[0]))
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	writeSet(c, set)
+	return nil
+}
+
+func (rn *RESPNode) handleSIsMember(c *client, args [][]byte) error {
+	set, _, err := rn.getSet(string(args[0]))
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	if _, ok := set[string(args[1])]; ok {
+		c.w.WriteInteger(1)
+		return nil
+	}
+
+	c.w.WriteInteger(0)
+	return nil
+}
+
+func (rn *RESPNode) handleSMIsMember(c *client, args [][]byte) error {
+	set, _, err := rn.getSet(string(args[0]))
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	c.w.WriteArray(len(args) - 1)
+	for _, member := range args[1:] {
+		if _, ok := set[string(member)]; ok {
+			c.w.WriteInteger(1)
+			continue
+		}
+		c.w.WriteInteger(0)
+	}
+
+	return nil
+}
+
+func (rn *RESPNode) handleSCard(c *client, args [][]byte) error {
+	set, _, err := rn.getSet(string(args[0]))
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	c.w.WriteInteger(int64(len(set)))
+	return nil
+}
+
+func (rn *RESPNode) handleSPop(c *client, args [][]byte) error {
+	key := string(args[0])
+
+	if len(args) > 2 {
+		c.w.WriteError(ErrSyntax.Error())
+		return nil
+	}
+
+	count := int64(-1)
+	if len(args) == 2 {
+		n, err := parseInt(args[1])
+		if err != nil || n < 0 {
+			c.w.WriteError("ERR value is out of range, must be positive")
+			return nil
+		}
+		count = n
+	}
+
+	set, ok, err := rn.getSet(key)
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	if !ok {
+		if count == -1 {
+			c.w.WriteNull()
+		} else {
+			c.w.WriteSet(0)
+		}
+		return nil
+	}
+
+	n := count
+	if count == -1 {
+		n = 1
+	}
+
+	popped := types.RandomKeys(set, int(min(n, math.MaxInt32)), false)
+
+	for _, member := range popped {
+		delete(set, member)
+	}
+
+	if len(set) == 0 {
+		rn.deleteKey(key)
+	}
+
+	if len(popped) > 0 {
+		c.dirty++
+
+		// replicas must remove the members picked here, not random ones
+		c.rewrite(append([]string{"srem", key}, popped...))
+	}
+
+	if count == -1 {
+		c.w.WriteBulkString(popped[0])
+		return nil
+	}
+
+	c.w.WriteSet(len(popped))
+	for _, member := range popped {
+		c.w.WriteBulkString(member)
+	}
+	return nil
+}
+
+func (rn *RESPNode) handleSRandMember(c *client, args [][]byte) error {
+	set, _, err := rn.getSet(string(args[0]))
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	if len(args) > 2 {
+		c.w.WriteError(ErrSyntax.Error())
+		return nil
+	}
+
+	if len(args) == 1 {
+		if len(set) == 0 {
+			c.w.WriteNull()
+			return nil
+		}
+
+		c.w.WriteBulkString(types.RandomKeys(set, 1, false)[0])
+		return nil
+	}
+
+	count, err := parseInt(args[1])
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	// a positive count picks distinct members, a negative one may repeat them
+	var picked []string
+	if count >= 0 {
+		picked = types.RandomKeys(set, int(min(count, math.MaxInt32)), false)
+	} else {
+		if count < -math.MaxInt32 && len(set) > 0 {
+			c.w.WriteError("ERR value is out of range")
+			return nil
+		}
+		picked = types.RandomKeys(set, int(-count), true)
+	}
+
+	c.w.WriteBulkStrings(picked)
+	return nil
+}
+
+func (rn *RESPNode) handleSMove(c *client, args [][]byte) error {
+	source, destination, member := string(args[0]), string(args[1]), string(args[2])
+
+	src, ok, err := rn.getSet(source)
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	dst, dstOk, err := rn.getSet(destination)
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	if _, found := src[member]; !ok || !found {
+		c.w.WriteInteger(0)
+		return nil
+	}
+
+	if source == destination {
+		c.w.WriteInteger(1)
+		return nil
+	}
+
+	delete(src, member)
+	if len(src) == 0 {
+		rn.deleteKey(source)
+	}
+
+	if !dstOk {
+		dst = types.NewSet()
+		rn.setCache.Store(destination, dst)
+	}
+	dst[member] = struct{}{}
+	c.dirty++
+
+	c.w.WriteInteger(1)
+	return nil
+}
+
+func (rn *RESPNode) handleSInter(c *client, args [][]byte) error {
+	sets, err := rn.getSets(args)
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	writeSet(c, types.Inter(sets, 0))
+	return nil
+}
+
+func (rn *RESPNode) handleSUnion(c *client, args [][]byte) error {
+	sets, err := rn.getSets(args)
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	writeSet(c, types.Union(sets))
+	return nil
+}
+
+func (rn *RESPNode) handleSDiff(c *client, args [][]byte) error {
+	sets, err := rn.getSets(args)
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	writeSet(c, types.Diff(sets))
+	return nil
+}
+
+func (rn *RESPNode) handleSInterStore(c *client, args [][]byte) error {
+	return rn.setStore(c, args, func(sets []types.Set) types.Set {
+		return types.Inter(sets, 0)
+	})
+}
+
+func (rn *RESPNode) handleSUnionStore(c *client, args [][]byte) error {
+	return rn.setStore(c, args, types.Union)
+}
+
+func (rn *RESPNode) handleSDiffStore(c *client, args [][]byte) error {
+	return rn.setStore(c, args, types.Diff)
+}
+
+// setStore implements the *STORE variants of the set algebra commands, whose
+// first argument is the destination and the rest the keys to combine.
+func (rn *RESPNode) setStore(
+	c *client,
+	args [][]byte,
+	combine func(sets []types.Set) types.Set,
+) error {
+	sets, err := rn.getSets(args[1:])
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	result := combine(sets)
+
+	rn.storeSet(string(args[0]), result)
+	c.dirty++
+
+	c.w.WriteInteger(int64(len(result)))
+	return nil
+}
+
+func (rn *RESPNode) handleSInterCard(c *client, args [][]byte) error {
+	numKeys, err := parseInt(args[0])
+	if err != nil || numKeys <= 0 {
+		c.w.WriteError("ERR numkeys should be greater than 0")
+		return nil
+	}
+
+	if numKeys > int64(len(args)-1) {
+		c.w.WriteError("ERR Number of keys can't be greater than number of args")
+		return nil
+	}
+
+	keys, options := args[1:1+numKeys], args[1+numKeys:]
+
+	limit := int64(0)
+	switch {
+	case len(options) == 2 && strings.EqualFold(string(options[0]), "limit"):
+		limit, err = parseInt(options[1])
+		if err != nil {
+			c.w.WriteError(err.Error())
+			return nil
+		}
+		if limit < 0 {
+			c.w.WriteError("ERR LIMIT can't be negative")
+			return nil
+		}
+	case len(options) != 0:
+		c.w.WriteError(ErrSyntax.Error())
+		return nil
+	}
+
+	sets, err := rn.getSets(keys)
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	c.w.WriteInteger(int64(len(types.Inter(sets, int(min(limit, math.MaxInt32))))))
+	return nil
+}
@@ -0,0 +1,728 @@
+package resp
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"nishojib/goredis/internal/types"
+)
+
+var errStreamExhausted = errors.New(
+	"ERR The stream has exhausted the last possible ID, unable to add more items",
+)
+
+// getStream returns the stream stored at key. It fails with ErrWrongType
+// when the key holds a value of another type.
+func (rn *RESPNode) getStream(key string) (*types.Stream, bool, error) {
+	keyType := rn.keyType(key)
+	if keyType == "none" {
+		return nil, false, nil
+	}
+	if keyType != "stream" {
+		return nil, false, ErrWrongType
+	}
+
+	stream, ok := rn.streamCache.Load(key)
+	return stream, ok, nil
+}
+
+// nextStreamID resolves the ID argument of XADD against the last ID of the
+// stream: * generates the whole ID, <ms>-* only its sequence number and an
+// explicit ID must be greater than last.
+func nextStreamID(last types.StreamID, arg string) (types.StreamID, error) {
+	if arg == "*" {
+		ms := uint64(time.Now().UnixMilli())
+		if ms > last.Ms {
+			return types.StreamID{Ms: ms}, nil
+		}
+
+		// the clock went backwards or several entries share a millisecond
+		id, ok := last.Next()
+		if !ok {
+			return types.StreamID{}, errStreamExhausted
+		}
+		return id, nil
+	}
+
+	if ms, ok := strings.CutSuffix(arg, "-*"); ok {
+		id, valid := types.ParseStreamID(ms, 0)
+		if !valid || strings.Contains(ms, "-") {
+			return types.StreamID{}, ErrInvalidStreamId
+		}
+
+		if id.Ms < last.Ms {
+			return types.StreamID{}, ErrInvalidId
+		}
+
+		if id.Ms == last.Ms {
+			next, ok := last.Next()
+			if !ok || next.Ms != id.Ms {
+				return types.StreamID{}, ErrInvalidId
+			}
+			id = next
+		}
+		return id, nil
+	}
+
+	id, ok := types.ParseStreamID(arg, 0)
+	if !ok {
+		return types.StreamID{}, ErrInvalidStreamId
+	}
+
+	if id == (types.StreamID{}) {
+		return types.StreamID{}, ErrGreaterThanZero
+	}
+
+	if id.Compare(last) <= 0 {
+		return types.StreamID{}, ErrInvalidId
+	}
+
+	return id, nil
+}
+
+// streamTrimOptions are the MAXLEN and MINID arguments XADD and XTRIM share.
+// Strategy is empty when they are not given, and limit is 0 for no limit.
+type streamTrimOptions struct {
+	strategy string
+	maxLen   int64
+	minID    types.StreamID
+	approx   bool
+	limit    int64
+}
+
+// parseStreamTrimOption parses the trim option starting at args[i], and
+// returns the index of the argument following it. It replies with an error
+// and returns -1 when it is invalid, and returns i when args[i] is not a trim
+// option.
+func parseStreamTrimOption(c *client, args [][]byte, i int, opts *streamTrimOptions) int {
+	option := strings.ToUpper(string(args[i]))
+
+	switch {
+	case (option == "MAXLEN" || option == "MINID") && i+1 < len(args):
+		opts.strategy = option
+		i++
+
+		if arg := string(args[i]); (arg == "~" || arg == "=") && i+1 < len(args) {
+			opts.approx = arg == "~"
+			i++
+		}
+
+		if option == "MAXLEN" {
+			n, err := parseInt(args[i])
+			if err != nil {
+				c.w.WriteError(err.Error())
+				return -1
+			}
+			if n < 0 {
+				c.w.WriteError("ERR The MAXLEN argument must be >= 0.")
+				return -1
+			}
+			opts.maxLen = n
+		} else {
+			id, ok := types.ParseStreamID(string(args[i]), 0)
+			if !ok {
+				c.w.WriteError(ErrInvalidStreamId.Error())
+				return -1
+			}
+			opts.minID = id
+		}
+		return i + 1
+
+	case option == "LIMIT" && i+1 < len(args):
+		n, err := parseInt(args[i+1])
+		if err != nil {
+			c.w.WriteError(err.Error())
+			return -1
+		}
+		if n < 0 {
+			c.w.WriteError("ERR The LIMIT argument must be >= 0.")
+			return -1
+		}
+		opts.limit = n
+		return i + 2
+	}
+
+	return i
+}
+
+// checkStreamTrimOptions validates the trim options once all of them are
+// parsed, and gives an approximate trim its default limit.
+func checkStreamTrimOptions(c *client, opts *streamTrimOptions, limitGiven bool) bool {
+	if limitGiven && !opts.approx {
+		c.w.WriteError("ERR syntax error, LIMIT cannot be used without the special ~ option")
+		return false
+	}
+
+	if opts.approx && !limitGiven {
+		opts.limit = 100 * 100
+	}
+	return true
+}
+
+// trimStream applies opts to stream and returns how many entries it removed.
+func trimStream(stream *types.Stream, opts streamTrimOptions) int {
+	limit := int(min(opts.limit, math.MaxInt32))
+
+	switch opts.strategy {
+	case "MAXLEN":
+		return stream.TrimMaxLen(int(min(opts.maxLen, math.MaxInt32)), opts.approx, limit)
+	case "MINID":
+		return stream.TrimMinID(opts.minID, opts.approx, limit)
+	}
+	return 0
+}
+
+func (rn *RESPNode) handleXAdd(c *client, args [][]byte) error {
+	key := string(args[0])
+
+	noMkStream := false
+	trim := streamTrimOptions{}
+	limitGiven := false
+
+	i := 1
+	for i < len(args) {
+		if strings.EqualFold(string(args[i]), "nomkstream") {
+			noMkStream = true
+			i++
+			continue
+		}
+
+		next := parseStreamTrimOption(c, args, i, &trim)
+		if next == -1 {
+			return nil
+		}
+		if next == i {
+			break
+		}
+
+		limitGiven = limitGiven || strings.EqualFold(string(args[i]), "limit")
+		i = next
+	}
+
+	if !checkStreamTrimOptions(c, &trim, limitGiven) {
+		return nil
+	}
+
+	if rest := len(args) - i; rest < 3 || rest%2 != 1 {
+		c.w.WriteError("ERR wrong number of arguments for 'xadd' command")
+		return nil
+	}
+
+	items := []types.StreamItem{}
+	fields := []string{}
+
+	for j := i + 1; j < len(args); j += 2 {
+		items = append(items, types.StreamItem{
+			Key:   string(args[j]),
+			Value: string(args[j+1]),
+		})
+		fields = append(fields, string(args[j]), string(args[j+1]))
+	}
+
+	stream, ok, err := rn.getStream(key)
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	if !ok {
+		if noMkStream {
+			c.w.WriteNull()
+			return nil
+		}
+		stream = types.NewStream()
+	}
+
+	id, err := nextStreamID(stream.LastID, string(args[i]))
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	stream.Append(id, items)
+	if !ok {
+		rn.streamCache.Store(key, stream)
+	}
+	c.dirty++
+
+	// replicas must store the entry under the ID generated here, and trim
+	// the stream to the same length however it was asked to
+	command := []string{"xadd", key}
+	if trim.strategy != "" {
+		trimStream(stream, trim)
+		command = append(command, "MAXLEN", "=", strconv.Itoa(len(stream.Entries)))
+	}
+	c.rewrite(append(append(command, id.String()), fields...))
+
+	rn.streamReady.Broadcast()
+
+	c.w.WriteBulkString(id.String())
+	return nil
+}
+
+func (rn *RESPNode) handleXLen(c *client, args [][]byte) error {
+	stream, ok, err := rn.getStream(string(args[0]))
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	if !ok {
+		c.w.WriteInteger(0)
+		return nil
+	}
+
+	c.w.WriteInteger(int64(len(stream.Entries)))
+	return nil
+}
+
+func (rn *RESPNode) handleXDel(c *client, args [][]byte) error {
+	ids := make([]types.StreamID, 0, len(args)-1)
+	for _, arg := range args[1:] {
+		id, ok := types.ParseStreamID(string(arg), 0)
+		if !ok {
+			c.w.WriteError(ErrInvalidStreamId.Error())
+			return nil
+		}
+		ids = append(ids, id)
+	}
+
+	stream, ok, err := rn.getStream(string(args[0]))
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	if !ok {
+		c.w.WriteInteger(0)
+		return nil
+	}
+
+	deleted := 0
+	for _, id := range ids {
+		if stream.Delete(id) {
+			deleted++
+		}
+	}
+
+	if deleted > 0 {
+		c.dirty++
+	}
+
+	c.w.WriteInteger(int64(deleted))
+	return nil
+}
+
+func (rn *RESPNode) handleXTrim(c *client, args [][]byte) error {
+	key := string(args[0])
+
+	trim := streamTrimOptions{}
+	limitGiven := false
+
+	for i := 1; i < len(args); {
+		next := parseStreamTrimOption(c, args, i, &trim)
+		if next == -1 {
+			return nil
+		}
+		if next == i {
+			c.w.WriteError(ErrSyntax.Error())
+			return nil
+		}
+
+		limitGiven = limitGiven || strings.EqualFold(string(args[i]), "limit")
+		i = next
+	}
+
+	if trim.strategy == "" {
+		c.w.WriteError(ErrSyntax.Error())
+		return nil
+	}
+
+	if !checkStreamTrimOptions(c, &trim, limitGiven) {
+		return nil
+	}
+
+	stream, ok, err := rn.getStream(key)
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	if !ok {
+		c.w.WriteInteger(0)
+		return nil
+	}
+
+	trimmed := trimStream(stream, trim)
+	if trimmed > 0 {
+		c.dirty++
+
+		// an approximate trim depends on how the entries were laid out, so
+		// replicas get the resulting length instead
+		c.rewrite([]string{"xtrim", key, "MAXLEN", "=", strconv.Itoa(len(stream.Entries))})
+	}
+
+	c.w.WriteInteger(int64(trimmed))
+	return nil
+}
+
+// streamRead is what XREAD replies with for one of the streams it reads.
+type streamRead struct {
+	key     string
+	entries []types.StreamEntry
+}
+
+// streamReadOptions are the arguments XREAD and XREADGROUP share. Count is 0
+// and block -1 when they are not given.
+type streamReadOptions struct {
+	count int
+	block int64
+	noAck bool
+	keys  [][]byte
+	ids   [][]byte
+}
+
+// parseStreamReadOptions parses the arguments of XREAD, or those following
+// GROUP <group> <consumer> for XREADGROUP when group is set. It replies with
+// an error and returns false when they are invalid.
+func parseStreamReadOptions(c *client, args [][]byte, group bool) (streamReadOptions, bool) {
+	opts := streamReadOptions{block: -1}
+	streams := -1
+
+	for i := 0; i < len(args) && streams == -1; i++ {
+		option := strings.ToUpper(string(args[i]))
+
+		switch {
+		case option == "COUNT" && i+1 < len(args):
+			n, err := parseInt(args[i+1])
+			if err != nil {
+				c.w.WriteError(err.Error())
+				return opts, false
+			}
+			opts.count = int(max(n, 0))
+			i++
+		case option == "BLOCK" && i+1 < len(args):
+			n, err := parseInt(args[i+1])
+			if err != nil {
+				c.w.WriteError("ERR timeout is not an integer or out of range")
+				return opts, false
+			}
+			if n < 0 {
+				c.w.WriteError("ERR timeout is negative")
+				return opts, false
+			}
+			opts.block = n
+			i++
+		case option == "NOACK" && group:
+			opts.noAck = true
+		case option == "STREAMS":
+			streams = i + 1
+		default:
+			c.w.WriteError(ErrSyntax.Error())
+			return opts, false
+		}
+	}
+
+	if streams == -1 {
+		c.w.WriteError(ErrSyntax.Error())
+		return opts, false
+	}
+
+	if rest := len(args) - streams; rest == 0 || rest%2 != 0 {
+		if group {
+			c.w.WriteError(
+				"ERR Unbalanced 'xreadgroup' list of streams: " +
+					"for each stream key an ID or '>' must be specified.",
+			)
+		} else {
+			c.w.WriteError(
+				"ERR Unbalanced 'xread' list of streams: " +
+					"for each stream key an ID or '$' must be specified.",
+			)
+		}
+		return opts, false
+	}
+
+	n := (len(args) - streams) / 2
+	opts.keys, opts.ids = args[streams:streams+n], args[streams+n:]
+
+	return opts, true
+}
+
+// waitForStreams calls read until it reports that it replied, waiting for
+// new stream entries in between for up to block milliseconds, or for as long
+// as it takes when block is 0. A negative block replies nil right away.
+func (rn *RESPNode) waitForStreams(c *client, block int64, read func() (bool, error)) error {
+	timedOut := false
+	if block > 0 {
+		timer := time.AfterFunc(time.Duration(block)*time.Millisecond, func() {
+			rn.mu.Lock()
+			defer rn.mu.Unlock()
+
+			timedOut = true
+			rn.streamReady.Broadcast()
+		})
+		defer timer.Stop()
+	}
+
+	gone, stop := c.watchClose(func() {
+		rn.mu.Lock()
+		defer rn.mu.Unlock()
+
+		rn.streamReady.Broadcast()
+	})
+	defer stop()
+
+	for {
+		replied, err := read()
+		if err != nil || replied {
+			return err
+		}
+
+		if block < 0 || timedOut {
+			c.w.WriteNullArray()
+			return nil
+		}
+
+		// replies to earlier pipelined commands should not wait with us
+		if err := c.w.Flush(); err != nil {
+			return err
+		}
+
+		rn.streamReady.Wait()
+		rn.current = c
+
+		// the goroutine of a client that went away would otherwise wait
+		// forever
+		if gone() {
+			return nil
+		}
+	}
+}
+
+func (rn *RESPNode) handleXRead(c *client, args [][]byte) error {
+	opts, ok := parseStreamReadOptions(c, args, false)
+	if !ok {
+		return nil
+	}
+
+	// entries are read after these IDs, which $ and + resolve to once here
+	// so that waiting for new entries does not move them
+	after := make([]types.StreamID, len(opts.keys))
+
+	for i, key := range opts.keys {
+		stream, ok, err := rn.getStream(string(key))
+		if err != nil {
+			c.w.WriteError(err.Error())
+			return nil
+		}
+
+		switch string(opts.ids[i]) {
+		case "$":
+			if ok {
+				after[i] = stream.LastID
+			}
+		case "+":
+			if ok {
+				after[i] = stream.LastID
+				if len(stream.Entries) > 0 {
+					after[i], _ = stream.Entries[len(stream.Entries)-1].ID.Prev()
+				}
+			}
+		default:
+			id, valid := types.ParseStreamID(string(opts.ids[i]), 0)
+			if !valid {
+				c.w.WriteError(ErrInvalidStreamId.Error())
+				return nil
+			}
+			after[i] = id
+		}
+	}
+
+	return rn.waitForStreams(c, opts.block, func() (bool, error) {
+		reads := []streamRead{}
+		for i, key := range opts.keys {
+			if rn.keyType(string(key)) != "stream" {
+				continue
+			}
+
+			stream, _ := rn.streamCache.Load(string(key))
+
+			if entries := stream.After(after[i], opts.count); len(entries) > 0 {
+				reads = append(reads, streamRead{key: string(key), entries: entries})
+			}
+		}
+
+		if len(reads) == 0 {
+			return false, nil
+		}
+
+		writeStreamReads(c, reads)
+		return true, nil
+	})
+}
+
+// writeStreamReads writes the reply of XREAD, a map from stream key to
+// entries in RESP3 and an array of key and entries pairs in RESP2.
+func writeStreamReads(c *client, reads []streamRead) {
+	if c.w.Protocol == 3 {
+		c.w.WriteMap(len(reads))
+	} else {
+		c.w.WriteArray(len(reads))
+	}
+
+	for _, read := range reads {
+		if c.w.Protocol != 3 {
+			c.w.WriteArray(2)
+		}
+		c.w.WriteBulkString(read.key)
+		writeStreamEntries(c, read.entries)
+	}
+}
+
+func writeStreamEntries(c *client, entries []types.StreamEntry) {
+	c.w.WriteArray(len(entries))
+	for _, entry := range entries {
+		writeStreamEntry(c, entry)
+	}
+}
+
+func writeStreamEntry(c *client, entry types.StreamEntry) {
+	c.w.WriteArray(2)
+	c.w.WriteBulkString(entry.ID.String())
+
+	// entries deleted while pending are reported without fields
+	if entry.Items == nil {
+		c.w.WriteNullArray()
+		return
+	}
+
+	fields := make([]string, 0, 2*len(entry.Items))
+	for _, item := range entry.Items {
+		fields = append(fields, item.Key, item.Value)
+	}
+	c.w.WriteBulkStrings(fields)
+}
+
+func (rn *RESPNode) handleXRange(c *client, args [][]byte) error {
+	return rn.xrange(c, args[0], args[1], args[2], args[3:], false)
+}
+
+func (rn *RESPNode) handleXRevRange(c *client, args [][]byte) error {
+	return rn.xrange(c, args[0], args[2], args[1], args[3:], true)
+}
+
+func (rn *RESPNode) xrange(
+	c *client,
+	key []byte,
+	startArg []byte,
+	endArg []byte,
+	options [][]byte,
+	rev bool,
+) error {
+	count := -1
+	switch {
+	case len(options) == 2 && strings.EqualFold(string(options[0]), "count"):
+		n, err := parseInt(options[1])
+		if err != nil {
+			c.w.WriteError(err.Error())
+			return nil
+		}
+		count = int(max(n, 0))
+	case len(options) != 0:
+		c.w.WriteError(ErrSyntax.Error())
+		return nil
+	}
+
+	start, err := parseRangeID(string(startArg), true)
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	end, err := parseRangeID(string(endArg), false)
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	stream, ok, err := rn.getStream(string(key))
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	if !ok || count == 0 {
+		c.w.WriteArray(0)
+		return nil
+	}
+
+	// the range is a view of the stream, so XREVRANGE walks it backwards
+	// rather than reversing a copy
+	entries := stream.Range(start, end)
+	if count > 0 && len(entries) > count {
+		if rev {
+			entries = entries[len(entries)-count:]
+		} else {
+			entries = entries[:count]
+		}
+	}
+
+	if !rev {
+		writeStreamEntries(c, entries)
+		return nil
+	}
+
+	c.w.WriteArray(len(entries))
+	for i := len(entries) - 1; i >= 0; i-- {
+		writeStreamEntry(c, entries[i])
+	}
+	return nil
+}
+
+// parseRangeID parses the start or end bound of XRANGE. - and + are the
+// smallest and largest IDs, a bare <ms> covers the whole millisecond and a
+// leading ( makes the bound exclusive.
+func parseRangeID(arg string, start bool) (types.StreamID, error) {
+	side, defaultSeq := "start", uint64(0)
+	if !start {
+		side, defaultSeq = "end", math.MaxUint64
+	}
+
+	switch arg {
+	case "-":
+		return types.StreamID{}, nil
+	case "+":
+		return types.StreamID{Ms: math.MaxUint64, Seq: math.MaxUint64}, nil
+	}
+
+	exclusive := strings.HasPrefix(arg, "(")
+	if exclusive {
+		arg = arg[1:]
+	}
+
+	id, ok := types.ParseStreamID(arg, defaultSeq)
+	if !ok {
+		return types.StreamID{}, ErrInvalidStreamId
+	}
+
+	if !exclusive {
+		return id, nil
+	}
+
+	// excluding an ID is including the one right next to it
+	if start {
+		id, ok = id.Next()
+	} else {
+		id, ok = id.Prev()
+	}
+
+	if !ok {
+		return types.StreamID{}, fmt.Errorf("ERR invalid %s ID for the interval", side)
+	}
+	return id, nil
+}
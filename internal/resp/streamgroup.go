@@ -0,0 +1,976 @@
+package resp
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"nishojib/goredis/internal/types"
+)
+
+const errXGroupNoKey = "ERR The XGROUP subcommand requires the key to exist. " +
+	"Note that for CREATE you may want to use the MKSTREAM option to create an empty stream automatically."
+
+func errNoGroup(key string, group string) error {
+	return fmt.Errorf("NOGROUP No such key '%s' or consumer group '%s'", key, group)
+}
+
+// getStreamGroup returns the stream stored at key and its consumer group
+// called name. It fails with ErrWrongType when the key holds a value of
+// another type and with a NOGROUP error when either of them is missing.
+func (rn *RESPNode) getStreamGroup(key string, name string) (*types.Stream, *types.ConsumerGroup, error) {
+	stream, ok, err := rn.getStream(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !ok {
+		return nil, nil, errNoGroup(key, name)
+	}
+
+	group, ok := stream.Groups[name]
+	if !ok {
+		return nil, nil, errNoGroup(key, name)
+	}
+
+	return stream, group, nil
+}
+
+// claimCommand is how the delivery of a pending entry reaches replicas: an
+// XCLAIM that sets every field of the entry instead of deriving them.
+func claimCommand(key string, group *types.ConsumerGroup, pe *types.PendingEntry) []string {
+	return []string{
+		"xclaim", key, group.Name, pe.Consumer.Name, "0", pe.ID.String(),
+		"TIME", strconv.FormatInt(pe.DeliveryTime, 10),
+		"RETRYCOUNT", strconv.FormatInt(pe.DeliveryCount, 10),
+		"FORCE", "JUSTID", "LASTID", group.LastID.String(),
+	}
+}
+
+// setIDCommand propagates where a group is at in its stream.
+func setIDCommand(key string, group *types.ConsumerGroup) []string {
+	return []string{
+		"xgroup", "setid", key, group.Name, group.LastID.String(),
+		"ENTRIESREAD", strconv.FormatInt(group.EntriesRead, 10),
+	}
+}
+
+func (rn *RESPNode) handleXGroup(c *client, args [][]byte) error {
+	subcommand := strings.ToLower(string(args[0]))
+
+	arities := map[string][2]int{
+		"create":         {4, 7},
+		"setid":          {4, 6},
+		"destroy":        {3, 3},
+		"createconsumer": {4, 4},
+		"delconsumer":    {4, 4},
+		"help":           {1, 1},
+	}
+
+	arity, ok := arities[subcommand]
+	if !ok {
+		c.w.WriteError(fmt.Sprintf("ERR unknown subcommand '%s'. Try XGROUP HELP.", args[0]))
+		return nil
+	}
+
+	if len(args) < arity[0] || len(args) > arity[1] {
+		c.w.WriteError(fmt.Sprintf(
+			"ERR wrong number of arguments for 'xgroup|%s' command",
+			subcommand,
+		))
+		return nil
+	}
+
+	if subcommand == "help" {
+		c.w.WriteBulkStrings([]string{
+			"XGROUP <subcommand> [<arg> [value] [opt] ...]. Subcommands are:",
+			"CREATE <key> <groupname> <id|$> [option]",
+			"    Create a new consumer group. Options are:",
+			"    * MKSTREAM",
+			"      Create the empty stream if it does not exist.",
+			"    * ENTRIESREAD entries_read",
+			"      Set the group's entries_read counter (internal use).",
+			"CREATECONSUMER <key> <groupname> <consumer>",
+			"    Create a new consumer in the specified group.",
+			"DELCONSUMER <key> <groupname> <consumer>",
+			"    Remove the specified consumer.",
+			"DESTROY <key> <groupname>",
+			"    Remove the specified group.",
+			"SETID <key> <groupname> <id|$> [ENTRIESREAD entries_read]",
+			"    Set the current group ID and entries_read counter.",
+			"HELP",
+			"    Print this help.",
+		})
+		return nil
+	}
+
+	key, name := string(args[1]), string(args[2])
+
+	mkStream := false
+	entriesRead := int64(-2)
+
+	if subcommand == "create" || subcommand == "setid" {
+		for i := 4; i < len(args); i++ {
+			option := strings.ToUpper(string(args[i]))
+
+			switch {
+			case option == "MKSTREAM" && subcommand == "create":
+				mkStream = true
+			case option == "ENTRIESREAD" && i+1 < len(args):
+				n, err := parseInt(args[i+1])
+				if err != nil {
+					c.w.WriteError(err.Error())
+					return nil
+				}
+				if n < -1 {
+					c.w.WriteError("ERR value for ENTRIESREAD must be positive or -1")
+					return nil
+				}
+				entriesRead = n
+				i++
+			default:
+				c.w.WriteError(ErrSyntax.Error())
+				return nil
+			}
+		}
+	}
+
+	stream, ok, err := rn.getStream(key)
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	if !ok && !mkStream {
+		c.w.WriteError(errXGroupNoKey)
+		return nil
+	}
+
+	var group *types.ConsumerGroup
+	groupOk := false
+	if ok {
+		group, groupOk = stream.Groups[name]
+	}
+
+	if !groupOk && (subcommand == "setid" || strings.HasSuffix(subcommand, "consumer")) {
+		c.w.WriteError(fmt.Sprintf(
+			"NOGROUP No such consumer group '%s' for key name '%s'",
+			name,
+			key,
+		))
+		return nil
+	}
+
+	switch subcommand {
+	case "create", "setid":
+		if subcommand == "create" && groupOk {
+			c.w.WriteError("BUSYGROUP Consumer Group name already exists")
+			return nil
+		}
+
+		if !ok {
+			stream = types.NewStream()
+		}
+
+		var id types.StreamID
+		if string(args[3]) == "$" {
+			id = stream.LastID
+		} else {
+			var valid bool
+			id, valid = types.ParseStreamID(string(args[3]), 0)
+			if !valid {
+				c.w.WriteError(ErrInvalidStreamId.Error())
+				return nil
+			}
+		}
+
+		if entriesRead == -2 {
+			entriesRead = stream.EntriesReadAt(id)
+		}
+
+		if subcommand == "create" {
+			group = types.NewConsumerGroup(name, id, entriesRead)
+			stream.Groups[name] = group
+		} else {
+			group.LastID, group.EntriesRead = id, entriesRead
+		}
+
+		if !ok {
+			rn.streamCache.Store(key, stream)
+		}
+		c.dirty++
+
+		// replicas get the resolved ID and counter instead of $
+		if subcommand == "create" {
+			c.rewrite([]string{
+				"xgroup", "create", key, name, id.String(), "MKSTREAM",
+				"ENTRIESREAD", strconv.FormatInt(entriesRead, 10),
+			})
+		} else {
+			c.rewrite(setIDCommand(key, group))
+		}
+
+		c.w.WriteSimpleString("OK")
+
+	case "destroy":
+		if !groupOk {
+			c.w.WriteInteger(0)
+			return nil
+		}
+
+		delete(stream.Groups, name)
+		c.dirty++
+
+		// readers blocked on the group find out it is gone
+		rn.streamReady.Broadcast()
+
+		c.w.WriteInteger(1)
+
+	case "createconsumer":
+		_, created := group.CreateConsumer(string(args[3]), time.Now().UnixMilli())
+		if !created {
+			c.w.WriteInteger(0)
+			return nil
+		}
+		c.dirty++
+
+		c.w.WriteInteger(1)
+
+	case "delconsumer":
+		pending, deleted := group.DeleteConsumer(string(args[3]))
+		if deleted {
+			c.dirty++
+		}
+
+		c.w.WriteInteger(int64(pending))
+	}
+
+	return nil
+}
+
+func (rn *RESPNode) handleXReadGroup(c *client, args [][]byte) error {
+	if !strings.EqualFold(string(args[0]), "group") {
+		c.w.WriteError(ErrSyntax.Error())
+		return nil
+	}
+
+	name, consumerName := string(args[1]), string(args[2])
+
+	opts, ok := parseStreamReadOptions(c, args[3:], true)
+	if !ok {
+		return nil
+	}
+
+	after := make([]types.StreamID, len(opts.keys))
+	for i, id := range opts.ids {
+		switch string(id) {
+		case ">":
+		case "$":
+			c.w.WriteError(
+				"ERR The $ ID is meaningless in the context of XREADGROUP: " +
+					"you want to read the history of this consumer by specifying a proper ID, " +
+					"or use the > ID to get new messages. " +
+					"The $ ID would just return an empty result set.",
+			)
+			return nil
+		default:
+			var valid bool
+			after[i], valid = types.ParseStreamID(string(id), 0)
+			if !valid {
+				c.w.WriteError(ErrInvalidStreamId.Error())
+				return nil
+			}
+		}
+	}
+
+	for _, key := range opts.keys {
+		if _, _, err := rn.getStreamGroup(string(key), name); err != nil {
+			c.w.WriteError(err.Error() + " in XREADGROUP with GROUP option")
+			return nil
+		}
+	}
+
+	// only reads of new entries wait for them, reading history never blocks
+	block := opts.block
+	for _, id := range opts.ids {
+		if string(id) != ">" {
+			block = -1
+		}
+	}
+
+	return rn.waitForStreams(c, block, func() (bool, error) {
+		reads := []streamRead{}
+		now := time.Now().UnixMilli()
+
+		for i, key := range opts.keys {
+			stream, group, err := rn.getStreamGroup(string(key), name)
+			if err != nil {
+				c.w.WriteError(err.Error() + " in XREADGROUP with GROUP option")
+				return true, nil
+			}
+
+			consumer, created := group.CreateConsumer(consumerName, now)
+			consumer.SeenTime = now
+			if created {
+				c.dirty++
+				c.repl = append(c.repl, []string{
+					"xgroup", "createconsumer", string(key), name, consumerName,
+				})
+			}
+
+			if string(opts.ids[i]) != ">" {
+				entries := []types.StreamEntry{}
+				consumer.Pending.Ascend(after[i], func(pe *types.PendingEntry) bool {
+					if pe.ID.Compare(after[i]) == 0 {
+						return true
+					}
+					if opts.count > 0 && len(entries) >= opts.count {
+						return false
+					}
+
+					entry, ok := stream.Entry(pe.ID)
+					if !ok {
+						entry = types.StreamEntry{ID: pe.ID}
+					}
+					entries = append(entries, entry)
+					return true
+				})
+
+				reads = append(reads, streamRead{key: string(key), entries: entries})
+				continue
+			}
+
+			entries := stream.After(group.LastID, opts.count)
+			if len(entries) == 0 {
+				continue
+			}
+
+			for _, entry := range entries {
+				stream.Deliver(group, entry.ID)
+
+				if opts.noAck {
+					continue
+				}
+
+				pe := group.Assign(entry.ID, consumer)
+				pe.DeliveryTime, pe.DeliveryCount = now, 1
+				c.repl = append(c.repl, claimCommand(string(key), group, pe))
+			}
+
+			consumer.ActiveTime = now
+			c.dirty++
+			c.repl = append(c.repl, setIDCommand(string(key), group))
+
+			reads = append(reads, streamRead{key: string(key), entries: entries})
+		}
+
+		if len(reads) == 0 {
+			return false, nil
+		}
+
+		writeStreamReads(c, reads)
+		return true, nil
+	})
+}
+
+func (rn *RESPNode) handleXAck(c *client, args [][]byte) error {
+	key, name := string(args[0]), string(args[1])
+
+	ids := make([]types.StreamID, 0, len(args)-2)
+	for _, arg := range args[2:] {
+		id, ok := types.ParseStreamID(string(arg), 0)
+		if !ok {
+			c.w.WriteError(ErrInvalidStreamId.Error())
+			return nil
+		}
+		ids = append(ids, id)
+	}
+
+	stream, ok, err := rn.getStream(key)
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	if !ok || stream.Groups[name] == nil {
+		c.w.WriteInteger(0)
+		return nil
+	}
+
+	acked := 0
+	for _, id := range ids {
+		if stream.Groups[name].Ack(id) {
+			acked++
+		}
+	}
+
+	if acked > 0 {
+		c.dirty++
+	}
+
+	c.w.WriteInteger(int64(acked))
+	return nil
+}
+
+func (rn *RESPNode) handleXPending(c *client, args [][]byte) error {
+	key, name := string(args[0]), string(args[1])
+
+	if len(args) == 2 {
+		_, group, err := rn.getStreamGroup(key, name)
+		if err != nil {
+			c.w.WriteError(err.Error())
+			return nil
+		}
+
+		pending := group.Pending
+		if pending.Len() == 0 {
+			c.w.WriteArray(4)
+			c.w.WriteInteger(0)
+			c.w.WriteNull()
+			c.w.WriteNull()
+			c.w.WriteNullArray()
+			return nil
+		}
+
+		consumers := []*types.Consumer{}
+		for _, consumer := range group.Consumers {
+			if consumer.Pending.Len() > 0 {
+				consumers = append(consumers, consumer)
+			}
+		}
+		slices.SortFunc(consumers, func(a, b *types.Consumer) int {
+			return strings.Compare(a.Name, b.Name)
+		})
+
+		c.w.WriteArray(4)
+		c.w.WriteInteger(int64(pending.Len()))
+		c.w.WriteBulkString(pending.First().ID.String())
+		c.w.WriteBulkString(pending.Last().ID.String())
+		c.w.WriteArray(len(consumers))
+		for _, consumer := range consumers {
+			c.w.WriteBulkStrings([]string{
+				consumer.Name,
+				strconv.Itoa(consumer.Pending.Len()),
+			})
+		}
+		return nil
+	}
+
+	options := args[2:]
+
+	minIdle := int64(0)
+	if strings.EqualFold(string(options[0]), "idle") && len(options) > 1 {
+		n, err := parseInt(options[1])
+		if err != nil {
+			c.w.WriteError(err.Error())
+			return nil
+		}
+		minIdle = n
+		options = options[2:]
+	}
+
+	if len(options) != 3 && len(options) != 4 {
+		c.w.WriteError(ErrSyntax.Error())
+		return nil
+	}
+
+	start, err := parseRangeID(string(options[0]), true)
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	end, err := parseRangeID(string(options[1]), false)
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	count, err := parseInt(options[2])
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	_, group, err := rn.getStreamGroup(key, name)
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	pending := group.Pending
+	if len(options) == 4 {
+		consumer, ok := group.Consumers[string(options[3])]
+		if !ok {
+			c.w.WriteArray(0)
+			return nil
+		}
+		pending = consumer.Pending
+	}
+
+	now := time.Now().UnixMilli()
+
+	matches := []*types.PendingEntry{}
+	pending.Ascend(start, func(pe *types.PendingEntry) bool {
+		if int64(len(matches)) >= count || pe.ID.Compare(end) > 0 {
+			return false
+		}
+
+		if now-pe.DeliveryTime >= minIdle {
+			matches = append(matches, pe)
+		}
+		return true
+	})
+
+	c.w.WriteArray(len(matches))
+	for _, pe := range matches {
+		c.w.WriteArray(4)
+		c.w.WriteBulkString(pe.ID.String())
+		c.w.WriteBulkString(pe.Consumer.Name)
+		c.w.WriteInteger(now - pe.DeliveryTime)
+		c.w.WriteInteger(pe.DeliveryCount)
+	}
+
+	return nil
+}
+
+// claimOptions are the options XCLAIM accepts after its IDs. DeliveryTime is
+// the time claimed entries are delivered at and retryCount their delivery
+// count, which is incremented instead when it is -1.
+type claimOptions struct {
+	deliveryTime int64
+	retryCount   int64
+	force        bool
+	justID       bool
+	lastID       types.StreamID
+}
+
+func (rn *RESPNode) handleXClaim(c *client, args [][]byte) error {
+	key, name, consumerName := string(args[0]), string(args[1]), string(args[2])
+
+	minIdle, err := parseInt(args[3])
+	if err != nil {
+		c.w.WriteError("ERR Invalid min-idle-time argument for XCLAIM")
+		return nil
+	}
+
+	ids := []types.StreamID{}
+
+	i := 4
+	for ; i < len(args); i++ {
+		id, ok := types.ParseStreamID(string(args[i]), 0)
+		if !ok {
+			break
+		}
+		ids = append(ids, id)
+	}
+
+	if len(ids) == 0 {
+		c.w.WriteError(ErrInvalidStreamId.Error())
+		return nil
+	}
+
+	now := time.Now().UnixMilli()
+	opts := claimOptions{deliveryTime: now, retryCount: -1}
+
+	for ; i < len(args); i++ {
+		option := strings.ToUpper(string(args[i]))
+
+		switch {
+		case option == "FORCE":
+			opts.force = true
+		case option == "JUSTID":
+			opts.justID = true
+		case option == "LASTID" && i+1 < len(args):
+			id, ok := types.ParseStreamID(string(args[i+1]), 0)
+			if !ok {
+				c.w.WriteError(ErrInvalidStreamId.Error())
+				return nil
+			}
+			opts.lastID = id
+			i++
+		case (option == "IDLE" || option == "TIME" || option == "RETRYCOUNT") && i+1 < len(args):
+			n, err := parseInt(args[i+1])
+			if err != nil {
+				c.w.WriteError(fmt.Sprintf("ERR Invalid %s option argument for XCLAIM", option))
+				return nil
+			}
+
+			switch option {
+			case "IDLE":
+				opts.deliveryTime = now - n
+			case "TIME":
+				opts.deliveryTime = n
+			default:
+				opts.retryCount = n
+			}
+			i++
+		default:
+			c.w.WriteError(fmt.Sprintf("ERR Unrecognized XCLAIM option '%s'", args[i]))
+			return nil
+		}
+	}
+
+	stream, group, err := rn.getStreamGroup(key, name)
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	if opts.lastID.Compare(group.LastID) > 0 {
+		group.LastID = opts.lastID
+		c.dirty++
+		c.repl = append(c.repl, setIDCommand(key, group))
+	}
+
+	consumer := rn.claimingConsumer(c, key, group, consumerName, now)
+
+	claimed := []types.StreamEntry{}
+	for _, id := range ids {
+		pe, ok := group.Pending.Get(id)
+
+		entry, exists := stream.Entry(id)
+		if !ok && opts.force && exists {
+			pe = group.Assign(id, consumer)
+			pe.DeliveryTime = now
+			ok = true
+		}
+
+		if !ok || now-pe.DeliveryTime < minIdle {
+			continue
+		}
+
+		if !exists {
+			rn.ackDeleted(c, key, group, id)
+			continue
+		}
+
+		claimed = append(claimed, entry)
+		rn.claim(c, key, group, pe, consumer, opts)
+	}
+
+	if len(claimed) > 0 {
+		consumer.ActiveTime = now
+	}
+
+	writeClaimed(c, claimed, opts.justID)
+	return nil
+}
+
+func (rn *RESPNode) handleXAutoClaim(c *client, args [][]byte) error {
+	key, name, consumerName := string(args[0]), string(args[1]), string(args[2])
+
+	minIdle, err := parseInt(args[3])
+	if err != nil {
+		c.w.WriteError("ERR Invalid min-idle-time argument for XAUTOCLAIM")
+		return nil
+	}
+
+	start, err := parseRangeID(string(args[4]), true)
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	now := time.Now().UnixMilli()
+	opts := claimOptions{deliveryTime: now, retryCount: -1}
+	count := int64(100)
+
+	for i := 5; i < len(args); i++ {
+		option := strings.ToUpper(string(args[i]))
+
+		switch {
+		case option == "JUSTID":
+			opts.justID = true
+		case option == "COUNT" && i+1 < len(args):
+			count, err = parseInt(args[i+1])
+			if err != nil || count < 1 {
+				c.w.WriteError("ERR COUNT must be > 0")
+				return nil
+			}
+			i++
+		default:
+			c.w.WriteError(ErrSyntax.Error())
+			return nil
+		}
+	}
+
+	stream, group, err := rn.getStreamGroup(key, name)
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	consumer := rn.claimingConsumer(c, key, group, consumerName, now)
+
+	claimed := []types.StreamEntry{}
+	deleted := []string{}
+	next := types.StreamID{}
+
+	// like Redis, look at no more than ten times count entries per call
+	attempts := count * 10
+
+	// claiming changes the pending entries, so the ones to look at are
+	// gathered first, which is at most one past the attempts
+	candidates := []*types.PendingEntry{}
+	group.Pending.Ascend(start, func(pe *types.PendingEntry) bool {
+		candidates = append(candidates, pe)
+		return int64(len(candidates)) <= attempts
+	})
+
+	for _, pe := range candidates {
+		if int64(len(claimed)) >= count || attempts == 0 {
+			next = pe.ID
+			break
+		}
+		attempts--
+
+		if now-pe.DeliveryTime < minIdle {
+			continue
+		}
+
+		entry, exists := stream.Entry(pe.ID)
+		if !exists {
+			rn.ackDeleted(c, key, group, pe.ID)
+			deleted = append(deleted, pe.ID.String())
+			continue
+		}
+
+		claimed = append(claimed, entry)
+		rn.claim(c, key, group, pe, consumer, opts)
+	}
+
+	if len(claimed) > 0 {
+		consumer.ActiveTime = now
+	}
+
+	c.w.WriteArray(3)
+	c.w.WriteBulkString(next.String())
+	writeClaimed(c, claimed, opts.justID)
+	c.w.WriteBulkStrings(deleted)
+	return nil
+}
+
+// claimingConsumer returns the consumer of group called name, creating it
+// when needed, and notes that it was just seen.
+func (rn *RESPNode) claimingConsumer(
+	c *client,
+	key string,
+	group *types.ConsumerGroup,
+	name string,
+	now int64,
+) *types.Consumer {
+	consumer, created := group.CreateConsumer(name, now)
+	consumer.SeenTime = now
+
+	if created {
+		c.dirty++
+		c.repl = append(c.repl, []string{"xgroup", "createconsumer", key, group.Name, name})
+	}
+
+	return consumer
+}
+
+// claim hands the pending entry pe over to consumer.
+func (rn *RESPNode) claim(
+	c *client,
+	key string,
+	group *types.ConsumerGroup,
+	pe *types.PendingEntry,
+	consumer *types.Consumer,
+	opts claimOptions,
+) {
+	group.Assign(pe.ID, consumer)
+
+	pe.DeliveryTime = opts.deliveryTime
+	switch {
+	case opts.retryCount >= 0:
+		pe.DeliveryCount = opts.retryCount
+	case !opts.justID:
+		pe.DeliveryCount++
+	}
+
+	c.dirty++
+	c.repl = append(c.repl, claimCommand(key, group, pe))
+}
+
+// ackDeleted drops a pending entry whose stream entry no longer exists.
+func (rn *RESPNode) ackDeleted(c *client, key string, group *types.ConsumerGroup, id types.StreamID) {
+	group.Ack(id)
+
+	c.dirty++
+	c.repl = append(c.repl, []string{"xack", key, group.Name, id.String()})
+}
+
+func writeClaimed(c *client, claimed []types.StreamEntry, justID bool) {
+	if !justID {
+		writeStreamEntries(c, claimed)
+		return
+	}
+
+	c.w.WriteArray(len(claimed))
+	for _, entry := range claimed {
+		c.w.WriteBulkString(entry.ID.String())
+	}
+}
+
+func (rn *RESPNode) handleXInfo(c *client, args [][]byte) error {
+	subcommand := strings.ToLower(string(args[0]))
+
+	arities := map[string]int{"stream": 2, "groups": 2, "consumers": 3, "help": 1}
+
+	arity, ok := arities[subcommand]
+	if !ok {
+		c.w.WriteError(fmt.Sprintf("ERR unknown subcommand '%s'. Try XINFO HELP.", args[0]))
+		return nil
+	}
+
+	if len(args) != arity {
+		c.w.WriteError(fmt.Sprintf(
+			"ERR wrong number of arguments for 'xinfo|%s' command",
+			subcommand,
+		))
+		return nil
+	}
+
+	if subcommand == "help" {
+		c.w.WriteBulkStrings([]string{
+			"XINFO <subcommand> [<arg> [value] [opt] ...]. Subcommands are:",
+			"CONSUMERS <key> <groupname>",
+			"    Show consumers of <groupname>.",
+			"GROUPS <key>",
+			"    Show the stream consumer groups.",
+			"STREAM <key>",
+			"    Show information about the stream.",
+			"HELP",
+			"    Print this help.",
+		})
+		return nil
+	}
+
+	key := string(args[1])
+
+	stream, ok, err := rn.getStream(key)
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	if !ok {
+		c.w.WriteError("ERR no such key")
+		return nil
+	}
+
+	now := time.Now().UnixMilli()
+
+	switch subcommand {
+	case "stream":
+		first := types.StreamID{}
+		if len(stream.Entries) > 0 {
+			first = stream.Entries[0].ID
+		}
+
+		c.w.WriteMap(8)
+		c.w.WriteBulkString("length")
+		c.w.WriteInteger(int64(len(stream.Entries)))
+		c.w.WriteBulkString("last-generated-id")
+		c.w.WriteBulkString(stream.LastID.String())
+		c.w.WriteBulkString("max-deleted-entry-id")
+		c.w.WriteBulkString(stream.MaxDeletedID.String())
+		c.w.WriteBulkString("entries-added")
+		c.w.WriteInteger(stream.EntriesAdded)
+		c.w.WriteBulkString("recorded-first-entry-id")
+		c.w.WriteBulkString(first.String())
+		c.w.WriteBulkString("groups")
+		c.w.WriteInteger(int64(len(stream.Groups)))
+
+		for i, field := range []string{"first-entry", "last-entry"} {
+			c.w.WriteBulkString(field)
+			if len(stream.Entries) == 0 {
+				c.w.WriteNull()
+				continue
+			}
+
+			entry := stream.Entries[0]
+			if i == 1 {
+				entry = stream.Entries[len(stream.Entries)-1]
+			}
+			writeStreamEntry(c, entry)
+		}
+
+	case "groups":
+		names := make([]string, 0, len(stream.Groups))
+		for name := range stream.Groups {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+
+		c.w.WriteArray(len(names))
+		for _, name := range names {
+			group := stream.Groups[name]
+
+			c.w.WriteMap(6)
+			c.w.WriteBulkString("name")
+			c.w.WriteBulkString(group.Name)
+			c.w.WriteBulkString("consumers")
+			c.w.WriteInteger(int64(len(group.Consumers)))
+			c.w.WriteBulkString("pending")
+			c.w.WriteInteger(int64(group.Pending.Len()))
+			c.w.WriteBulkString("last-delivered-id")
+			c.w.WriteBulkString(group.LastID.String())
+			c.w.WriteBulkString("entries-read")
+			if group.EntriesRead == -1 {
+				c.w.WriteNull()
+			} else {
+				c.w.WriteInteger(group.EntriesRead)
+			}
+			c.w.WriteBulkString("lag")
+			if lag, ok := stream.Lag(group); ok {
+				c.w.WriteInteger(lag)
+			} else {
+				c.w.WriteNull()
+			}
+		}
+
+	case "consumers":
+		group, ok := stream.Groups[string(args[2])]
+		if !ok {
+			c.w.WriteError(fmt.Sprintf(
+				"NOGROUP No such consumer group '%s' for key name '%s'",
+				args[2],
+				key,
+			))
+			return nil
+		}
+
+		consumers := make([]*types.Consumer, 0, len(group.Consumers))
+		for _, consumer := range group.Consumers {
+			consumers = append(consumers, consumer)
+		}
+		slices.SortFunc(consumers, func(a, b *types.Consumer) int {
+			return strings.Compare(a.Name, b.Name)
+		})
+
+		c.w.WriteArray(len(consumers))
+		for _, consumer := range consumers {
+			inactive := int64(-1)
+			if consumer.ActiveTime != -1 {
+				inactive = now - consumer.ActiveTime
+			}
+
+			c.w.WriteMap(4)
+			c.w.WriteBulkString("name")
+			c.w.WriteBulkString(consumer.Name)
+			c.w.WriteBulkString("pending")
+			c.w.WriteInteger(int64(consumer.Pending.Len()))
+			c.w.WriteBulkString("idle")
+			c.w.WriteInteger(now - consumer.SeenTime)
+			c.w.WriteBulkString("inactive")
+			c.w.WriteInteger(inactive)
+		}
+	}
+
+	return nil
+}
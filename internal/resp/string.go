@@ -0,0 +1,450 @@
+package resp
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"nishojib/goredis/internal/parser"
+	"nishojib/goredis/internal/types"
+)
+
+const errStringTooLong = "ERR string exceeds maximum allowed size (proto-max-bulk-len)"
+
+func (rn *RESPNode) handleIncr(c *client, args [][]byte) error {
+	return rn.incrBy(c, string(args[0]), 1)
+}
+
+func (rn *RESPNode) handleDecr(c *client, args [][]byte) error {
+	return rn.incrBy(c, string(args[0]), -1)
+}
+
+func (rn *RESPNode) handleIncrBy(c *client, args [][]byte) error {
+	delta, err := parseInt(args[1])
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	return rn.incrBy(c, string(args[0]), delta)
+}
+
+func (rn *RESPNode) handleDecrBy(c *client, args [][]byte) error {
+	delta, err := parseInt(args[1])
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	if delta == math.MinInt64 {
+		c.w.WriteError("ERR decrement would overflow")
+		return nil
+	}
+
+	return rn.incrBy(c, string(args[0]), -delta)
+}
+
+// incrBy adds delta to the integer stored at key, treating a missing key as
+// 0. The key keeps its time to live.
+func (rn *RESPNode) incrBy(c *client, key string, delta int64) error {
+	item, ok, err := rn.getString(key)
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	var current int64
+
+	if ok {
+		current, ok = parseStrictInt(string(item.Value))
+		if !ok {
+			c.w.WriteError(ErrNotInteger.Error())
+			return nil
+		}
+	}
+
+	if (delta > 0 && current > math.MaxInt64-delta) ||
+		(delta < 0 && current < math.MinInt64-delta) {
+		c.w.WriteError("ERR increment or decrement would overflow")
+		return nil
+	}
+
+	current += delta
+
+	rn.cache.Store(key, types.Item{
+		Value: strconv.AppendInt(nil, current, 10),
+		Type:  "string",
+	})
+	c.dirty++
+
+	c.w.WriteInteger(current)
+	return nil
+}
+
+func (rn *RESPNode) handleIncrByFloat(c *client, args [][]byte) error {
+	key := string(args[0])
+
+	incr, ok := parseFloat(string(args[1]))
+	if !ok {
+		c.w.WriteError("ERR value is not a valid float")
+		return nil
+	}
+
+	item, ok, err := rn.getString(key)
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	var current float64
+
+	if ok {
+		current, ok = parseFloat(string(item.Value))
+		if !ok {
+			c.w.WriteError("ERR value is not a valid float")
+			return nil
+		}
+	}
+
+	result := current + incr
+	if math.IsInf(result, 0) || math.IsNaN(result) {
+		c.w.WriteError("ERR increment would produce NaN or Infinity")
+		return nil
+	}
+
+	value := strconv.FormatFloat(result, 'f', -1, 64)
+
+	rn.cache.Store(key, types.Item{Value: []byte(value), Type: "string"})
+	c.dirty++
+
+	// float arithmetic may round differently elsewhere, so replicas get the
+	// result instead of the increment
+	c.rewrite([]string{"set", key, value, "KEEPTTL"})
+
+	c.w.WriteBulkString(value)
+	return nil
+}
+
+func (rn *RESPNode) handleAppend(c *client, args [][]byte) error {
+	key := string(args[0])
+
+	item, ok, err := rn.getString(key)
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	if !ok {
+		item = types.Item{Type: "string"}
+	}
+
+	if len(item.Value)+len(args[1]) > parser.MaxBulkLength {
+		c.w.WriteError(errStringTooLong)
+		return nil
+	}
+
+	item.Value = append(item.Value, args[1]...)
+
+	rn.cache.Store(key, item)
+	c.dirty++
+
+	c.w.WriteInteger(int64(len(item.Value)))
+	return nil
+}
+
+func (rn *RESPNode) handleStrlen(c *client, args [][]byte) error {
+	item, _, err := rn.getString(string(args[0]))
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	c.w.WriteInteger(int64(len(item.Value)))
+	return nil
+}
+
+func (rn *RESPNode) handleGetRange(c *client, args [][]byte) error {
+	start, err := parseInt(args[1])
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	end, err := parseInt(args[2])
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	item, _, err := rn.getString(string(args[0]))
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	value := item.Value
+	n := int64(len(value))
+
+	if start < 0 && end < 0 && start > end {
+		c.w.WriteBulkString("")
+		return nil
+	}
+
+	if start < 0 {
+		start = max(n+start, 0)
+	}
+	if end < 0 {
+		end = max(n+end, 0)
+	}
+	if end >= n {
+		end = n - 1
+	}
+
+	if n == 0 || start > end {
+		c.w.WriteBulkString("")
+		return nil
+	}
+
+	c.w.WriteBulk(value[start : end+1])
+	return nil
+}
+
+func (rn *RESPNode) handleSetRange(c *client, args [][]byte) error {
+	key, value := string(args[0]), args[2]
+
+	offset, err := parseInt(args[1])
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	if offset < 0 {
+		c.w.WriteError("ERR offset is out of range")
+		return nil
+	}
+
+	item, ok, err := rn.getString(key)
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	// writing nothing neither creates nor changes the key
+	if len(value) == 0 {
+		c.w.WriteInteger(int64(len(item.Value)))
+		return nil
+	}
+
+	if offset+int64(len(value)) > parser.MaxBulkLength {
+		c.w.WriteError(errStringTooLong)
+		return nil
+	}
+
+	if !ok {
+		item = types.Item{Type: "string"}
+	}
+
+	if end := int(offset) + len(value); end > len(item.Value) {
+		// the gap between the old end and the offset is filled with zeros
+		item.Value = append(item.Value, make([]byte, end-len(item.Value))...)
+	}
+	copy(item.Value[offset:], value)
+
+	rn.cache.Store(key, item)
+	c.dirty++
+
+	c.w.WriteInteger(int64(len(item.Value)))
+	return nil
+}
+
+func (rn *RESPNode) handleMGet(c *client, args [][]byte) error {
+	c.w.WriteArray(len(args))
+	for _, key := range args {
+		// keys holding other types read as missing instead of failing
+		item, ok, err := rn.getString(string(key))
+		if err != nil || !ok {
+			c.w.WriteNull()
+			continue
+		}
+		c.w.WriteBulk(item.Value)
+	}
+
+	return nil
+}
+
+func (rn *RESPNode) handleMSet(c *client, args [][]byte) error {
+	if len(args)%2 != 0 {
+		c.w.WriteError("ERR wrong number of arguments for 'mset' command")
+		return nil
+	}
+
+	rn.setStrings(c, args)
+
+	c.w.WriteSimpleString("OK")
+	return nil
+}
+
+func (rn *RESPNode) handleMSetNX(c *client, args [][]byte) error {
+	if len(args)%2 != 0 {
+		c.w.WriteError("ERR wrong number of arguments for 'msetnx' command")
+		return nil
+	}
+
+	// nothing is set unless none of the keys exist
+	for i := 0; i < len(args); i += 2 {
+		if rn.keyType(string(args[i])) != "none" {
+			c.w.WriteInteger(0)
+			return nil
+		}
+	}
+
+	rn.setStrings(c, args)
+
+	c.w.WriteInteger(1)
+	return nil
+}
+
+// setStrings stores every key value pair of args, dropping their time to
+// live.
+func (rn *RESPNode) setStrings(c *client, args [][]byte) {
+	for i := 0; i < len(args); i += 2 {
+		rn.setString(string(args[i]), types.Item{Value: args[i+1], Type: "string"}, -1)
+		c.dirty++
+	}
+}
+
+func (rn *RESPNode) handleSetNX(c *client, args [][]byte) error {
+	key := string(args[0])
+
+	if rn.keyType(key) != "none" {
+		c.w.WriteInteger(0)
+		return nil
+	}
+
+	rn.setString(key, types.Item{Value: args[1], Type: "string"}, -1)
+	c.dirty++
+
+	c.w.WriteInteger(1)
+	return nil
+}
+
+func (rn *RESPNode) handleSetEX(c *client, args [][]byte) error {
+	return rn.setWithExpiry(c, "EX", args)
+}
+
+func (rn *RESPNode) handlePSetEX(c *client, args [][]byte) error {
+	return rn.setWithExpiry(c, "PX", args)
+}
+
+// setWithExpiry implements SETEX and PSETEX, whose arguments are a key, a
+// time to live in the unit of option and a value.
+func (rn *RESPNode) setWithExpiry(c *client, option string, args [][]byte) error {
+	key, value := string(args[0]), string(args[2])
+
+	ttl, err := parseInt(args[1])
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	expiry, err := absoluteExpiry(option, ttl)
+	if err != nil {
+		name := "setex"
+		if option == "PX" {
+			name = "psetex"
+		}
+		c.w.WriteError(fmt.Sprintf("ERR invalid expire time in '%s' command", name))
+		return nil
+	}
+
+	rn.setString(key, types.Item{Value: args[2], Type: "string"}, expiry)
+	c.dirty++
+	c.rewrite([]string{"set", key, value, "PXAT", strconv.FormatInt(expiry, 10)})
+
+	c.w.WriteSimpleString("OK")
+	return nil
+}
+
+func (rn *RESPNode) handleGetDel(c *client, args [][]byte) error {
+	key := string(args[0])
+
+	item, ok, err := rn.getString(key)
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	if !ok {
+		c.w.WriteNull()
+		return nil
+	}
+
+	rn.deleteKey(key)
+	c.dirty++
+
+	c.w.WriteBulk(item.Value)
+	return nil
+}
+
+func (rn *RESPNode) handleGetEX(c *client, args [][]byte) error {
+	key := string(args[0])
+
+	option := ""
+	var expiry int64 = -1
+
+	for i := 1; i < len(args); i++ {
+		opt := strings.ToUpper(string(args[i]))
+
+		switch {
+		case opt == "PERSIST" && option == "":
+			option = opt
+		case (opt == "EX" || opt == "PX" || opt == "EXAT" || opt == "PXAT") &&
+			option == "" && i+1 < len(args):
+			n, err := parseInt(args[i+1])
+			if err != nil {
+				c.w.WriteError(err.Error())
+				return nil
+			}
+
+			expiry, err = absoluteExpiry(opt, n)
+			if err != nil {
+				c.w.WriteError("ERR invalid expire time in 'getex' command")
+				return nil
+			}
+
+			option = opt
+			i++
+		default:
+			c.w.WriteError(ErrSyntax.Error())
+			return nil
+		}
+	}
+
+	item, ok, err := rn.getString(key)
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	if !ok {
+		c.w.WriteNull()
+		return nil
+	}
+
+	switch option {
+	case "":
+	case "PERSIST":
+		if rn.expiryOf(key) != -1 {
+			rn.expires.Delete(key)
+			c.dirty++
+		}
+	default:
+		rn.setExpiry(key, expiry)
+		c.dirty++
+		c.rewrite([]string{"getex", key, "PXAT", strconv.FormatInt(expiry, 10)})
+	}
+
+	c.w.WriteBulk(item.Value)
+	return nil
+}
@@ -0,0 +1,726 @@
+package resp
+
+import (
+	"errors"
+	"math"
+	"strings"
+
+	"nishojib/goredis/internal/types"
+)
+
+var errNotFloat = errors.New("ERR value is not a valid float")
+var errScoreRange = errors.New("ERR min or max is not a float")
+var errLexRange = errors.New("ERR min or max not valid string range item")
+
+// getZSet returns the sorted set stored at key. It fails with ErrWrongType
+// when the key holds a value of another type.
+func (rn *RESPNode) getZSet(key string) (*types.ZSet, bool, error) {
+	keyType := rn.keyType(key)
+	if keyType == "none" {
+		return nil, false, nil
+	}
+	if keyType != "zset" {
+		return nil, false, ErrWrongType
+	}
+
+	zset, ok := rn.zsetCache.Load(key)
+	return zset, ok, nil
+}
+
+// deleteIfEmptyZSet removes key once its sorted set has no members left.
+func (rn *RESPNode) deleteIfEmptyZSet(key string, zset *types.ZSet) {
+	if zset.Len() == 0 {
+		rn.deleteKey(key)
+	}
+}
+
+// parseScoreRange parses the min and max of ZRANGEBYSCORE and friends, where
+// a leading ( makes a bound exclusive.
+func parseScoreRange(lo []byte, hi []byte) (types.ScoreRange, error) {
+	var r types.ScoreRange
+
+	bounds := []struct {
+		arg       string
+		value     *float64
+		exclusive *bool
+	}{
+		{string(lo), &r.Min, &r.MinEx},
+		{string(hi), &r.Max, &r.MaxEx},
+	}
+
+	for _, bound := range bounds {
+		arg := bound.arg
+		if strings.HasPrefix(arg, "(") {
+			*bound.exclusive = true
+			arg = arg[1:]
+		}
+
+		value, ok := parseFloat(arg)
+		if !ok {
+			return types.ScoreRange{}, errScoreRange
+		}
+		*bound.value = value
+	}
+
+	return r, nil
+}
+
+// parseLexRange parses the min and max of ZRANGEBYLEX and friends, which are
+// - or + for the ends of the set, or a member prefixed with [ when inclusive
+// and ( when exclusive.
+func parseLexRange(lo []byte, hi []byte) (types.LexRange, error) {
+	parse := func(arg string) (types.LexBound, error) {
+		switch {
+		case arg == "-":
+			return types.LexBound{Inf: -1}, nil
+		case arg == "+":
+			return types.LexBound{Inf: 1}, nil
+		case strings.HasPrefix(arg, "["):
+			return types.LexBound{Value: arg[1:]}, nil
+		case strings.HasPrefix(arg, "("):
+			return types.LexBound{Value: arg[1:], Exclusive: true}, nil
+		}
+		return types.LexBound{}, errLexRange
+	}
+
+	minBound, err := parse(string(lo))
+	if err != nil {
+		return types.LexRange{}, err
+	}
+
+	maxBound, err := parse(string(hi))
+	if err != nil {
+		return types.LexRange{}, err
+	}
+
+	return types.LexRange{Min: minBound, Max: maxBound}, nil
+}
+
+// writeZSetMembers writes members with their scores when withScores is set,
+// as pairs in RESP3 and flattened in RESP2.
+func writeZSetMembers(c *client, members []types.ZSetMember, withScores bool) {
+	if !withScores {
+		c.w.WriteArray(len(members))
+		for _, m := range members {
+			c.w.WriteBulkString(m.Member)
+		}
+		return
+	}
+
+	if c.w.Protocol == 3 {
+		c.w.WriteArray(len(members))
+	} else {
+		c.w.WriteArray(2 * len(members))
+	}
+
+	for _, m := range members {
+		if c.w.Protocol == 3 {
+			c.w.WriteArray(2)
+		}
+		c.w.WriteBulkString(m.Member)
+		c.w.WriteDouble(m.Score)
+	}
+}
+
+func (rn *RESPNode) handleZAdd(c *client, args [][]byte) error {
+	key := string(args[0])
+
+	var nx, xx, gt, lt, ch, incr bool
+
+	i := 1
+flags:
+	for ; i < len(args); i++ {
+		switch strings.ToUpper(string(args[i])) {
+		case "NX":
+			nx = true
+		case "XX":
+			xx = true
+		case "GT":
+			gt = true
+		case "LT":
+			lt = true
+		case "CH":
+			ch = true
+		case "INCR":
+			incr = true
+		default:
+			break flags
+		}
+	}
+
+	pairs := args[i:]
+	if len(pairs) == 0 || len(pairs)%2 != 0 {
+		c.w.WriteError(ErrSyntax.Error())
+		return nil
+	}
+
+	if nx && xx {
+		c.w.WriteError("ERR XX and NX options at the same time are not compatible")
+		return nil
+	}
+
+	if (gt && nx) || (lt && nx) || (gt && lt) {
+		c.w.WriteError("ERR GT, LT, and/or NX options at the same time are not compatible")
+		return nil
+	}
+
+	if incr && len(pairs) > 2 {
+		c.w.WriteError("ERR INCR option supports a single increment-element pair")
+		return nil
+	}
+
+	// nothing is added unless every score is valid
+	scores := make([]float64, 0, len(pairs)/2)
+	for j := 0; j < len(pairs); j += 2 {
+		score, ok := parseFloat(string(pairs[j]))
+		if !ok {
+			c.w.WriteError(errNotFloat.Error())
+			return nil
+		}
+		scores = append(scores, score)
+	}
+
+	zset, ok, err := rn.getZSet(key)
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	if !ok {
+		zset = types.NewZSet()
+	}
+
+	added, changed := 0, 0
+	var result float64
+	updated := false
+
+	for j, score := range scores {
+		member := string(pairs[2*j+1])
+
+		current, exists := zset.Score(member)
+		if (exists && nx) || (!exists && xx) {
+			continue
+		}
+
+		if exists {
+			if incr {
+				score += current
+				if math.IsNaN(score) {
+					c.w.WriteError("ERR resulting score is not a number (NaN)")
+					return nil
+				}
+			}
+
+			if (gt && score <= current) || (lt && score >= current) {
+				continue
+			}
+
+			if score != current {
+				zset.Add(member, score)
+				changed++
+			}
+		} else {
+			zset.Add(member, score)
+			added++
+		}
+
+		result, updated = score, true
+	}
+
+	if !ok && zset.Len() > 0 {
+		rn.zsetCache.Store(key, zset)
+	}
+
+	if added+changed > 0 {
+		c.dirty++
+	}
+
+	if incr {
+		if !updated {
+			c.w.WriteNull()
+			return nil
+		}
+		c.w.WriteDouble(result)
+		return nil
+	}
+
+	if ch {
+		added += changed
+	}
+
+	c.w.WriteInteger(int64(added))
+	return nil
+}
+
+func (rn *RESPNode) handleZIncrBy(c *client, args [][]byte) error {
+	key, member := string(args[0]), string(args[2])
+
+	incr, ok := parseFloat(string(args[1]))
+	if !ok {
+		c.w.WriteError(errNotFloat.Error())
+		return nil
+	}
+
+	zset, ok, err := rn.getZSet(key)
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	if !ok {
+		zset = types.NewZSet()
+		rn.zsetCache.Store(key, zset)
+	}
+
+	current, _ := zset.Score(member)
+
+	score := current + incr
+	if math.IsNaN(score) {
+		c.w.WriteError("ERR resulting score is not a number (NaN)")
+		return nil
+	}
+
+	zset.Add(member, score)
+	c.dirty++
+
+	c.w.WriteDouble(score)
+	return nil
+}
+
+func (rn *RESPNode) handleZCard(c *client, args [][]byte) error {
+	zset, ok, err := rn.getZSet(string(args[0]))
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	if !ok {
+		c.w.WriteInteger(0)
+		return nil
+	}
+
+	c.w.WriteInteger(int64(zset.Len()))
+	return nil
+}
+
+func (rn *RESPNode) handleZScore(c *client, args [][]byte) error {
+	zset, ok, err := rn.getZSet(string(args[0]))
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	if !ok {
+		c.w.WriteNull()
+		return nil
+	}
+
+	score, ok := zset.Score(string(args[1]))
+	if !ok {
+		c.w.WriteNull()
+		return nil
+	}
+
+	c.w.WriteDouble(score)
+	return nil
+}
+
+func (rn *RESPNode) handleZMScore(c *client, args [][]byte) error {
+	zset, ok, err := rn.getZSet(string(args[0]))
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	c.w.WriteArray(len(args) - 1)
+	for _, member := range args[1:] {
+		if !ok {
+			c.w.WriteNull()
+			continue
+		}
+
+		score, found := zset.Score(string(member))
+		if !found {
+			c.w.WriteNull()
+			continue
+		}
+		c.w.WriteDouble(score)
+	}
+
+	return nil
+}
+
+func (rn *RESPNode) handleZRank(c *client, args [][]byte) error {
+	return rn.zrank(c, args, false)
+}
+
+func (rn *RESPNode) handleZRevRank(c *client, args [][]byte) error {
+	return rn.zrank(c, args, true)
+}
+
+func (rn *RESPNode) zrank(c *client, args [][]byte, rev bool) error {
+	withScore := false
+	switch {
+	case len(args) == 3 && strings.EqualFold(string(args[2]), "withscore"):
+		withScore = true
+	case len(args) != 2:
+		c.w.WriteError(ErrSyntax.Error())
+		return nil
+	}
+
+	zset, ok, err := rn.getZSet(string(args[0]))
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	var rank int
+	if ok {
+		rank, ok = zset.Rank(string(args[1]), rev)
+	}
+
+	if !ok {
+		if withScore {
+			c.w.WriteNullArray()
+		} else {
+			c.w.WriteNull()
+		}
+		return nil
+	}
+
+	if !withScore {
+		c.w.WriteInteger(int64(rank))
+		return nil
+	}
+
+	score, _ := zset.Score(string(args[1]))
+
+	c.w.WriteArray(2)
+	c.w.WriteInteger(int64(rank))
+	c.w.WriteDouble(score)
+	return nil
+}
+
+func (rn *RESPNode) handleZCount(c *client, args [][]byte) error {
+	r, err := parseScoreRange(args[1], args[2])
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	return rn.zcount(c, args[0], r)
+}
+
+func (rn *RESPNode) handleZLexCount(c *client, args [][]byte) error {
+	r, err := parseLexRange(args[1], args[2])
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	return rn.zcount(c, args[0], r)
+}
+
+func (rn *RESPNode) zcount(c *client, key []byte, r types.ZRange) error {
+	zset, ok, err := rn.getZSet(string(key))
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	if !ok {
+		c.w.WriteInteger(0)
+		return nil
+	}
+
+	c.w.WriteInteger(int64(zset.Count(r)))
+	return nil
+}
+
+func (rn *RESPNode) handleZRange(c *client, args [][]byte) error {
+	return rn.zrange(c, args[0], args[1:], nil)
+}
+
+func (rn *RESPNode) handleZRangeStore(c *client, args [][]byte) error {
+	return rn.zrange(c, args[1], args[2:], args[0])
+}
+
+// zrange implements ZRANGE and, when destination is set, ZRANGESTORE. Args
+// start with the two bounds, which are positions unless BYSCORE or BYLEX
+// says otherwise.
+func (rn *RESPNode) zrange(c *client, key []byte, args [][]byte, destination []byte) error {
+	by := "rank"
+	rev, withScores, limit := false, false, false
+	offset, count := int64(0), int64(-1)
+
+	for i := 2; i < len(args); i++ {
+		option := strings.ToUpper(string(args[i]))
+
+		switch {
+		case option == "BYSCORE":
+			by = "score"
+		case option == "BYLEX":
+			by = "lex"
+		case option == "REV":
+			rev = true
+		case option == "WITHSCORES" && destination == nil:
+			withScores = true
+		case option == "LIMIT" && i+2 < len(args):
+			var err error
+			if offset, err = parseInt(args[i+1]); err != nil {
+				c.w.WriteError(err.Error())
+				return nil
+			}
+			if count, err = parseInt(args[i+2]); err != nil {
+				c.w.WriteError(err.Error())
+				return nil
+			}
+			limit = true
+			i += 2
+		default:
+			c.w.WriteError(ErrSyntax.Error())
+			return nil
+		}
+	}
+
+	if limit && by == "rank" {
+		c.w.WriteError(
+			"ERR syntax error, LIMIT is only supported in combination with either BYSCORE or BYLEX",
+		)
+		return nil
+	}
+
+	if withScores && by == "lex" {
+		c.w.WriteError("ERR syntax error, WITHSCORES not supported in combination with BYLEX")
+		return nil
+	}
+
+	// reversed score and member ranges are given from max to min
+	lo, hi := args[0], args[1]
+	if rev {
+		lo, hi = hi, lo
+	}
+
+	var r types.ZRange
+	var start, end int64
+	var err error
+
+	switch by {
+	case "score":
+		r, err = parseScoreRange(lo, hi)
+	case "lex":
+		r, err = parseLexRange(lo, hi)
+	default:
+		if start, err = parseInt(args[0]); err == nil {
+			end, err = parseInt(args[1])
+		}
+	}
+
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	zset, ok, err := rn.getZSet(string(key))
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	members := []types.ZSetMember{}
+	switch {
+	case !ok || offset < 0:
+	case by == "rank":
+		if start, end, ok := types.NormalizeRange(int(start), int(end), zset.Len()); ok {
+			members = zset.RangeByRank(start, end, rev)
+		}
+	default:
+		members = zset.Range(r, int(offset), int(count), rev)
+	}
+
+	if destination == nil {
+		writeZSetMembers(c, members, withScores)
+		return nil
+	}
+
+	result := types.NewZSet()
+	for _, m := range members {
+		result.Add(m.Member, m.Score)
+	}
+
+	rn.deleteKey(string(destination))
+	if result.Len() > 0 {
+		rn.zsetCache.Store(string(destination), result)
+	}
+	c.dirty++
+
+	c.w.WriteInteger(int64(result.Len()))
+	return nil
+}
+
+func (rn *RESPNode) handleZRem(c *client, args [][]byte) error {
+	key := string(args[0])
+
+	zset, ok, err := rn.getZSet(key)
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	if !ok {
+		c.w.WriteInteger(0)
+		return nil
+	}
+
+	removed := 0
+	for _, member := range args[1:] {
+		if zset.Remove(string(member)) {
+			removed++
+		}
+	}
+
+	rn.deleteIfEmptyZSet(key, zset)
+	if removed > 0 {
+		c.dirty++
+	}
+
+	c.w.WriteInteger(int64(removed))
+	return nil
+}
+
+func (rn *RESPNode) handleZRemRangeByRank(c *client, args [][]byte) error {
+	start, err := parseInt(args[1])
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	end, err := parseInt(args[2])
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	return rn.zremRange(c, args[0], func(zset *types.ZSet) []types.ZSetMember {
+		start, end, ok := types.NormalizeRange(int(start), int(end), zset.Len())
+		if !ok {
+			return nil
+		}
+		return zset.RangeByRank(start, end, false)
+	})
+}
+
+func (rn *RESPNode) handleZRemRangeByScore(c *client, args [][]byte) error {
+	r, err := parseScoreRange(args[1], args[2])
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	return rn.zremRange(c, args[0], func(zset *types.ZSet) []types.ZSetMember {
+		return zset.Range(r, 0, -1, false)
+	})
+}
+
+func (rn *RESPNode) handleZRemRangeByLex(c *client, args [][]byte) error {
+	r, err := parseLexRange(args[1], args[2])
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	return rn.zremRange(c, args[0], func(zset *types.ZSet) []types.ZSetMember {
+		return zset.Range(r, 0, -1, false)
+	})
+}
+
+// zremRange removes the members that find picks out of the sorted set at key.
+func (rn *RESPNode) zremRange(
+	c *client,
+	key []byte,
+	find func(zset *types.ZSet) []types.ZSetMember,
+) error {
+	zset, ok, err := rn.getZSet(string(key))
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	if !ok {
+		c.w.WriteInteger(0)
+		return nil
+	}
+
+	members := find(zset)
+	for _, m := range members {
+		zset.Remove(m.Member)
+	}
+
+	rn.deleteIfEmptyZSet(string(key), zset)
+	if len(members) > 0 {
+		c.dirty++
+	}
+
+	c.w.WriteInteger(int64(len(members)))
+	return nil
+}
+
+func (rn *RESPNode) handleZPopMin(c *client, args [][]byte) error {
+	return rn.zpop(c, args, false)
+}
+
+func (rn *RESPNode) handleZPopMax(c *client, args [][]byte) error {
+	return rn.zpop(c, args, true)
+}
+
+func (rn *RESPNode) zpop(c *client, args [][]byte, highest bool) error {
+	key := string(args[0])
+
+	if len(args) > 2 {
+		c.w.WriteError(ErrSyntax.Error())
+		return nil
+	}
+
+	count := int64(1)
+	if len(args) == 2 {
+		n, err := parseInt(args[1])
+		if err != nil || n < 0 {
+			c.w.WriteError("ERR value is out of range, must be positive")
+			return nil
+		}
+		count = n
+	}
+
+	zset, ok, err := rn.getZSet(key)
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return nil
+	}
+
+	members := []types.ZSetMember{}
+	if ok && count > 0 {
+		members = zset.RangeByRank(0, int(min(count, int64(zset.Len())))-1, highest)
+	}
+
+	for _, m := range members {
+		zset.Remove(m.Member)
+	}
+
+	if len(members) > 0 {
+		rn.deleteIfEmptyZSet(key, zset)
+		c.dirty++
+	}
+
+	// without a count the member and its score come as a flat pair
+	if len(args) == 1 {
+		c.w.WriteArray(2 * len(members))
+		for _, m := range members {
+			c.w.WriteBulkString(m.Member)
+			c.w.WriteDouble(m.Score)
+		}
+		return nil
+	}
+
+	writeZSetMembers(c, members, true)
+	return nil
+}
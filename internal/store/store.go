@@ -37,3 +37,50 @@ func (s *Store[T]) Delete(key string) {
 
 	delete(s.cache, key)
 }
+
+func (s *Store[T]) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.cache)
+}
+
+// Keys returns the keys of the store in no particular order.
+func (s *Store[T]) Keys() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys := make([]string, 0, len(s.cache))
+	for key := range s.cache {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// RandomKey returns a key of the store picked at random, relying on maps
+// being iterated from a random position.
+func (s *Store[T]) RandomKey() (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key := range s.cache {
+		return key, true
+	}
+	return "", false
+}
+
+// Sample returns up to n keys of the store, taken from a random position the
+// way RandomKey is.
+func (s *Store[T]) Sample(n int) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys := make([]string, 0, min(n, len(s.cache)))
+	for key := range s.cache {
+		if len(keys) == n {
+			break
+		}
+		keys = append(keys, key)
+	}
+	return keys
+}
@@ -0,0 +1,333 @@
+package types
+
+import "math"
+
+const (
+	geoStep   = 26
+	geoLatMin = -85.05112878
+	geoLatMax = 85.05112878
+	geoLonMin = -180.0
+	geoLonMax = 180.0
+
+	earthRadius = 6372797.560856
+	mercatorMax = 20037726.37
+
+	geoAlphabet = "0123456789bcdefghjkmnpqrstuvwxyz"
+)
+
+// geoHash is a cell of the grid that step bits of longitude and step bits of
+// latitude divide the map into. The bits interleave both, with latitude in
+// the even positions and longitude in the odd ones.
+type geoHash struct {
+	bits uint64
+	step uint
+}
+
+type geoRange struct {
+	min, max float64
+}
+
+var geoLonRange = geoRange{geoLonMin, geoLonMax}
+var geoLatRange = geoRange{geoLatMin, geoLatMax}
+
+func interleave(lat uint32, lon uint32) uint64 {
+	var bits uint64
+	for i := range 32 {
+		bits |= uint64(lat>>i&1) << (2 * i)
+		bits |= uint64(lon>>i&1) << (2*i + 1)
+	}
+	return bits
+}
+
+func deinterleave(bits uint64) (lat uint32, lon uint32) {
+	for i := range 32 {
+		lat |= uint32(bits>>(2*i)&1) << i
+		lon |= uint32(bits>>(2*i+1)&1) << i
+	}
+	return lat, lon
+}
+
+func geoEncode(lonRange, latRange geoRange, lon, lat float64, step uint) geoHash {
+	latOffset := (lat - latRange.min) / (latRange.max - latRange.min)
+	lonOffset := (lon - lonRange.min) / (lonRange.max - lonRange.min)
+
+	latOffset *= float64(uint64(1) << step)
+	lonOffset *= float64(uint64(1) << step)
+
+	return geoHash{interleave(uint32(latOffset), uint32(lonOffset)), step}
+}
+
+// geoDecode returns the longitudes and latitudes the cell spans.
+func geoDecode(lonRange, latRange geoRange, h geoHash) (geoRange, geoRange) {
+	ilat, ilon := deinterleave(h.bits)
+	cells := float64(uint64(1) << h.step)
+
+	lonScale := lonRange.max - lonRange.min
+	latScale := latRange.max - latRange.min
+
+	lon := geoRange{
+		lonRange.min + float64(ilon)/cells*lonScale,
+		lonRange.min + float64(ilon+1)/cells*lonScale,
+	}
+	lat := geoRange{
+		latRange.min + float64(ilat)/cells*latScale,
+		latRange.min + float64(ilat+1)/cells*latScale,
+	}
+	return lon, lat
+}
+
+// GeoEncode returns the 52 bit geohash of a position, which sorted set
+// members are scored with. It returns false when the position is outside the
+// area Web Mercator covers.
+func GeoEncode(lon float64, lat float64) (uint64, bool) {
+	if lon < geoLonMin || lon > geoLonMax || lat < geoLatMin || lat > geoLatMax {
+		return 0, false
+	}
+	return geoEncode(geoLonRange, geoLatRange, lon, lat, geoStep).bits, true
+}
+
+// GeoDecode returns the position at the center of the cell of a geohash.
+func GeoDecode(score uint64) (lon float64, lat float64) {
+	lonCell, latCell := geoDecode(geoLonRange, geoLatRange, geoHash{score, geoStep})
+
+	lon = min(max((lonCell.min+lonCell.max)/2, geoLonMin), geoLonMax)
+	lat = min(max((latCell.min+latCell.max)/2, geoLatMin), geoLatMax)
+	return lon, lat
+}
+
+// GeoHashString returns the standard 11 character geohash of the position
+// a score decodes to. Standard geohashes span latitudes from -90 to 90, so it
+// is encoded again over that range.
+func GeoHashString(score uint64) string {
+	lon, lat := GeoDecode(score)
+	bits := geoEncode(geoLonRange, geoRange{-90, 90}, lon, lat, geoStep).bits
+
+	// the 52 bits fill 10 characters and the 11th is read as 0
+	buf := make([]byte, 11)
+	for i := range buf {
+		idx := 0
+		if i < 10 {
+			idx = int(bits >> (52 - (i+1)*5) & 0x1f)
+		}
+		buf[i] = geoAlphabet[idx]
+	}
+	return string(buf)
+}
+
+func degToRad(deg float64) float64 {
+	return deg * math.Pi / 180
+}
+
+func radToDeg(rad float64) float64 {
+	return rad * 180 / math.Pi
+}
+
+// GeoDistance returns the distance in meters between two positions along the
+// surface of the earth.
+func GeoDistance(lon1, lat1, lon2, lat2 float64) float64 {
+	lat1r, lon1r := degToRad(lat1), degToRad(lon1)
+	lat2r, lon2r := degToRad(lat2), degToRad(lon2)
+
+	u := math.Sin((lat2r - lat1r) / 2)
+	v := math.Sin((lon2r - lon1r) / 2)
+	return 2 * earthRadius * math.Asin(math.Sqrt(u*u+math.Cos(lat1r)*math.Cos(lat2r)*v*v))
+}
+
+// GeoShape is the area a geo search looks in, either a circle of Radius
+// meters or, when Box is set, a box of Width by Height meters centered on the
+// position.
+type GeoShape struct {
+	Lon, Lat      float64
+	Radius        float64
+	Box           bool
+	Width, Height float64
+}
+
+// Contains reports whether the position is within the shape, and returns its
+// distance in meters from the center.
+func (s GeoShape) Contains(lon float64, lat float64) (float64, bool) {
+	if !s.Box {
+		distance := GeoDistance(s.Lon, s.Lat, lon, lat)
+		return distance, distance <= s.Radius
+	}
+
+	// the latitude is the cheaper one to check
+	if earthRadius*math.Abs(degToRad(lat)-degToRad(s.Lat)) > s.Height/2 {
+		return 0, false
+	}
+	if GeoDistance(lon, lat, s.Lon, lat) > s.Width/2 {
+		return 0, false
+	}
+	return GeoDistance(s.Lon, s.Lat, lon, lat), true
+}
+
+// Ranges returns the ranges of scores to look for members of the shape in.
+// They are those of the cell holding the center and of its eight neighbours,
+// at a step where the cells are just large enough for them to cover the
+// whole shape, leaving out neighbours the shape does not reach.
+func (s GeoShape) Ranges() []ScoreRange {
+	halfWidth, halfHeight, radius := s.Radius, s.Radius, s.Radius
+	if s.Box {
+		halfWidth, halfHeight = s.Width/2, s.Height/2
+		radius = math.Hypot(halfWidth, halfHeight)
+	}
+
+	latDelta := radToDeg(halfHeight / earthRadius)
+	lonDeltaTop := radToDeg(halfWidth / earthRadius / math.Cos(degToRad(s.Lat+latDelta)))
+	lonDeltaBottom := radToDeg(halfWidth / earthRadius / math.Cos(degToRad(s.Lat-latDelta)))
+
+	// the shape is widest on the side nearest to the equator
+	lonDelta := lonDeltaTop
+	if s.Lat < 0 {
+		lonDelta = lonDeltaBottom
+	}
+	minLon, maxLon := s.Lon-lonDelta, s.Lon+lonDelta
+	minLat, maxLat := s.Lat-latDelta, s.Lat+latDelta
+
+	step := geoStepsByRadius(radius, s.Lat)
+	center, neighbours := s.cells(step)
+
+	// near the edges of the center cell a neighbour may be too small to
+	// reach the end of the shape, in which case larger cells are needed
+	if step > 1 {
+		north, south, east, west := neighbours[0], neighbours[1], neighbours[2], neighbours[3]
+		_, northLat := geoDecode(geoLonRange, geoLatRange, north)
+		_, southLat := geoDecode(geoLonRange, geoLatRange, south)
+		eastLon, _ := geoDecode(geoLonRange, geoLatRange, east)
+		westLon, _ := geoDecode(geoLonRange, geoLatRange, west)
+
+		if northLat.max < maxLat || southLat.min > minLat ||
+			eastLon.max < maxLon || westLon.min > minLon {
+			step--
+			center, neighbours = s.cells(step)
+		}
+	}
+
+	// neighbours are north, south, east, west, north east, north west,
+	// south east and south west
+	skip := make([]bool, len(neighbours))
+	if step >= 2 {
+		lon, lat := geoDecode(geoLonRange, geoLatRange, center)
+		if lat.min < minLat {
+			skip[1], skip[6], skip[7] = true, true, true
+		}
+		if lat.max > maxLat {
+			skip[0], skip[4], skip[5] = true, true, true
+		}
+		if lon.min < minLon {
+			skip[3], skip[5], skip[7] = true, true, true
+		}
+		if lon.max > maxLon {
+			skip[2], skip[4], skip[6] = true, true, true
+		}
+	}
+
+	cells := []geoHash{center}
+	for i, cell := range neighbours {
+		if !skip[i] {
+			cells = append(cells, cell)
+		}
+	}
+
+	// at the coarsest steps neighbours wrap around onto each other
+	seen := map[geoHash]bool{}
+	ranges := []ScoreRange{}
+	for _, cell := range cells {
+		if seen[cell] {
+			continue
+		}
+		seen[cell] = true
+
+		shift := 2 * (geoStep - cell.step)
+		ranges = append(ranges, ScoreRange{
+			Min:   float64(cell.bits << shift),
+			Max:   float64((cell.bits + 1) << shift),
+			MaxEx: true,
+		})
+	}
+	return ranges
+}
+
+// cells returns the cell holding the center of the shape at step and its
+// neighbours, in the order Ranges expects them.
+func (s GeoShape) cells(step uint) (geoHash, []geoHash) {
+	center := geoEncode(geoLonRange, geoLatRange, s.Lon, s.Lat, step)
+
+	move := func(dx, dy int) geoHash {
+		return center.moveX(dx).moveY(dy)
+	}
+
+	return center, []geoHash{
+		move(0, 1), move(0, -1), move(1, 0), move(-1, 0),
+		move(1, 1), move(-1, 1), move(1, -1), move(-1, -1),
+	}
+}
+
+// moveX returns the cell east of h when d is positive and west of it when
+// negative, wrapping around the map.
+func (h geoHash) moveX(d int) geoHash {
+	if d == 0 {
+		return h
+	}
+
+	x := h.bits & 0xaaaaaaaaaaaaaaaa
+	y := h.bits & 0x5555555555555555
+	zz := uint64(0x5555555555555555) >> (64 - h.step*2)
+
+	if d > 0 {
+		x = x + (zz + 1)
+	} else {
+		x = x | zz
+		x = x - (zz + 1)
+	}
+	x &= 0xaaaaaaaaaaaaaaaa >> (64 - h.step*2)
+
+	return geoHash{x | y, h.step}
+}
+
+// moveY returns the cell north of h when d is positive and south of it when
+// negative.
+func (h geoHash) moveY(d int) geoHash {
+	if d == 0 {
+		return h
+	}
+
+	x := h.bits & 0xaaaaaaaaaaaaaaaa
+	y := h.bits & 0x5555555555555555
+	zz := uint64(0xaaaaaaaaaaaaaaaa) >> (64 - h.step*2)
+
+	if d > 0 {
+		y = y + (zz + 1)
+	} else {
+		y = y | zz
+		y = y - (zz + 1)
+	}
+	y &= 0x5555555555555555 >> (64 - h.step*2)
+
+	return geoHash{x | y, h.step}
+}
+
+// geoStepsByRadius returns the step at which cells are about as large as
+// radius meters at the given latitude.
+func geoStepsByRadius(radius float64, lat float64) uint {
+	if radius == 0 {
+		return geoStep
+	}
+
+	step := 1
+	for radius < mercatorMax {
+		radius *= 2
+		step++
+	}
+	step -= 2
+
+	// cells get narrower towards the poles
+	if lat > 66 || lat < -66 {
+		step--
+		if lat > 80 || lat < -80 {
+			step--
+		}
+	}
+
+	return uint(min(max(step, 1), geoStep))
+}
@@ -0,0 +1,159 @@
+package types
+
+import (
+	"fmt"
+	"testing"
+)
+
+// the places and the values Redis reports for them come from the examples
+// of the GEO commands in the Redis documentation
+var geoPlaces = []struct {
+	name     string
+	lon, lat float64
+	score    uint64
+	hash     string
+	// pos is the position GEOPOS reports, at the center of the cell
+	pos string
+	// km is the distance in kilometers from 15,37
+	km string
+}{
+	{
+		name:  "Palermo",
+		lon:   13.361389,
+		lat:   38.115556,
+		score: 3479099956230698,
+		hash:  "sqc8b49rny0",
+		pos:   "13.36138933897018433 38.11555639549629859",
+		km:    "190.4424",
+	},
+	{
+		name:  "Catania",
+		lon:   15.087269,
+		lat:   37.502669,
+		score: 3479447370796909,
+		hash:  "sqdtr74hyu0",
+		pos:   "15.08726745843887329 37.50266842333162032",
+		km:    "56.4413",
+	},
+	{
+		name:  "edge1",
+		lon:   12.758489,
+		lat:   38.788135,
+		score: 3479273021651468,
+		hash:  "sqchdm4mq20",
+		pos:   "12.75848776102066040 38.78813451624225195",
+		km:    "279.7405",
+	},
+	{
+		name:  "edge2",
+		lon:   17.241510,
+		lat:   38.788135,
+		score: 3481342659049484,
+		hash:  "squk8m4vk20",
+		pos:   "17.24151045083999634 38.78813451624225195",
+		km:    "279.7403",
+	},
+}
+
+func TestGeoEncode(t *testing.T) {
+	for _, tt := range geoPlaces {
+		t.Run(tt.name, func(t *testing.T) {
+			score, ok := GeoEncode(tt.lon, tt.lat)
+			if !ok {
+				t.Fatalf("GeoEncode(%v, %v) is out of range", tt.lon, tt.lat)
+			}
+			if score != tt.score {
+				t.Errorf("GeoEncode(%v, %v) = %d, want %d", tt.lon, tt.lat, score, tt.score)
+			}
+
+			lon, lat := GeoDecode(score)
+			if pos := fmt.Sprintf("%.17f %.17f", lon, lat); pos != tt.pos {
+				t.Errorf("GeoDecode(%d) = %s, want %s", score, pos, tt.pos)
+			}
+
+			if hash := GeoHashString(score); hash != tt.hash {
+				t.Errorf("GeoHashString(%d) = %s, want %s", score, hash, tt.hash)
+			}
+
+			if km := fmt.Sprintf("%.4f", GeoDistance(15, 37, lon, lat)/1000); km != tt.km {
+				t.Errorf("GeoDistance() from 15,37 = %s km, want %s km", km, tt.km)
+			}
+		})
+	}
+}
+
+func TestGeoEncodeOutOfRange(t *testing.T) {
+	tests := []struct {
+		lon, lat float64
+	}{
+		{-180.1, 0},
+		{180.1, 0},
+		{0, 85.06},
+		{0, -85.06},
+	}
+
+	for _, tt := range tests {
+		if _, ok := GeoEncode(tt.lon, tt.lat); ok {
+			t.Errorf("GeoEncode(%v, %v) is in range", tt.lon, tt.lat)
+		}
+	}
+}
+
+func TestGeoDistance(t *testing.T) {
+	palermo, catania := geoPlaces[0], geoPlaces[1]
+
+	tests := []struct {
+		name     string
+		from, to uint64
+		want     string
+	}{
+		{"Palermo to Catania", palermo.score, catania.score, "166274.1516"},
+		{"Catania to Palermo", catania.score, palermo.score, "166274.1516"},
+		{"Palermo to itself", palermo.score, palermo.score, "0.0000"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lon1, lat1 := GeoDecode(tt.from)
+			lon2, lat2 := GeoDecode(tt.to)
+
+			if got := fmt.Sprintf("%.4f", GeoDistance(lon1, lat1, lon2, lat2)); got != tt.want {
+				t.Errorf("GeoDistance() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGeoShapeContains(t *testing.T) {
+	tests := []struct {
+		name  string
+		shape GeoShape
+		want  []string
+	}{
+		{
+			name:  "200 km radius",
+			shape: GeoShape{Lon: 15, Lat: 37, Radius: 200000},
+			want:  []string{"Palermo", "Catania"},
+		},
+		{
+			name:  "400 by 400 km box",
+			shape: GeoShape{Lon: 15, Lat: 37, Box: true, Width: 400000, Height: 400000},
+			want:  []string{"Palermo", "Catania", "edge1", "edge2"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, place := range geoPlaces {
+				if _, ok := tt.shape.Contains(GeoDecode(place.score)); ok {
+					got = append(got, place.name)
+				}
+			}
+
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("Contains() holds %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,14 @@
+package types
+
+import "maps"
+
+// Hash maps the fields of a hash to their values.
+type Hash map[string]string
+
+func NewHash() Hash {
+	return Hash{}
+}
+
+func (h Hash) Clone() Hash {
+	return maps.Clone(h)
+}
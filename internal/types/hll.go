@@ -0,0 +1,508 @@
+package types
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"slices"
+)
+
+const (
+	hllP         = 14
+	hllQ         = 64 - hllP
+	hllRegisters = 1 << hllP
+	hllBits      = 6
+	hllHeader    = 16
+	hllDenseSize = hllHeader + (hllRegisters*hllBits+7)/8
+
+	hllRegisterMax uint8 = 1<<hllBits - 1
+
+	hllDense  = 0
+	hllSparse = 1
+
+	// hllSparseMaxBytes is the size past which a sparse HyperLogLog is
+	// converted to the dense encoding, as hll-sparse-max-bytes defaults to.
+	hllSparseMaxBytes = 3000
+	hllSparseMaxValue = 32
+	hllSparseMaxVal   = 4
+	hllSparseMaxZero  = 64
+
+	// hllCardStale is the bit of the last header byte that marks the cached
+	// cardinality as stale.
+	hllCardStale = 0x80
+
+	hllAlphaInf = 0.721347520444481703680
+)
+
+var ErrNotHLL = errors.New("not a valid HyperLogLog string value")
+var ErrCorruptHLL = errors.New("corrupted HyperLogLog")
+
+// HLL is a HyperLogLog of 2^14 registers that estimates the number of
+// distinct elements added to it. It works on the string representation Redis
+// uses, which it reads and updates in place: a 16 byte header starting with
+// HYLL followed by the registers, either packed in 6 bits each or run length
+// encoded while most are zero.
+type HLL struct {
+	buf []byte
+}
+
+func NewHLL() *HLL {
+	buf := make([]byte, hllHeader, hllHeader+2)
+	copy(buf, "HYLL")
+	buf[4] = hllSparse
+
+	return &HLL{buf: appendZeroRun(buf, hllRegisters)}
+}
+
+// ParseHLL wraps the string representation of a HyperLogLog, which the
+// returned HLL goes on to update in place. Like Redis it only checks the
+// header here, sparse registers that do not add up are found when they are
+// read.
+func ParseHLL(buf []byte) (*HLL, error) {
+	if len(buf) < hllHeader || string(buf[:4]) != "HYLL" || buf[4] > hllSparse {
+		return nil, ErrNotHLL
+	}
+
+	if buf[4] == hllDense && len(buf) != hllDenseSize {
+		return nil, ErrNotHLL
+	}
+
+	return &HLL{buf: buf}, nil
+}
+
+// Bytes returns the string representation of h, which changes may have
+// moved to a new slice.
+func (h *HLL) Bytes() []byte {
+	return h.buf
+}
+
+func (h *HLL) dense() bool {
+	return h.buf[4] == hllDense
+}
+
+func (h *HLL) invalidateCache() {
+	h.buf[hllHeader-1] |= hllCardStale
+}
+
+// Add adds element and reports whether that changed any register.
+func (h *HLL) Add(element []byte) (bool, error) {
+	hash := murmurHash64A(element, 0xadc83b19)
+
+	index := int(hash & (hllRegisters - 1))
+
+	// the run of zeros is counted over the remaining bits, with a sentinel
+	// bit so that it ends within them
+	hash >>= hllP
+	hash |= 1 << hllQ
+	count := uint8(1)
+	for bit := uint64(1); hash&bit == 0; bit <<= 1 {
+		count++
+	}
+
+	return h.set(index, count)
+}
+
+// set raises register i to value unless it already holds as much, and
+// reports whether it did.
+func (h *HLL) set(i int, value uint8) (bool, error) {
+	if !h.dense() {
+		return h.sparseSet(i, value)
+	}
+
+	data := h.buf[hllHeader:]
+	if denseRegister(data, i) >= value {
+		return false, nil
+	}
+
+	setDenseRegister(data, i, value)
+	h.invalidateCache()
+	return true, nil
+}
+
+// sparseSet is set for the sparse encoding, done the way hllSparseSet does
+// it in Redis so that the result matches byte for byte: the opcode covering
+// the register is split around it, then equal neighbouring values merged.
+func (h *HLL) sparseSet(i int, value uint8) (bool, error) {
+	if value > hllSparseMaxValue {
+		if err := h.toDense(); err != nil {
+			return false, err
+		}
+		return h.set(i, value)
+	}
+
+	p, prev, first := hllHeader, hllHeader, 0
+	var old uint8
+	var run, size int
+
+	for {
+		if p >= len(h.buf) {
+			return false, ErrCorruptHLL
+		}
+
+		var ok bool
+		old, run, size, ok = sparseOp(h.buf[p:])
+		if !ok {
+			return false, ErrCorruptHLL
+		}
+
+		if i < first+run {
+			break
+		}
+
+		prev = p
+		p += size
+		first += run
+	}
+
+	isVal := h.buf[p]&0x80 != 0
+	if isVal && old >= value {
+		return false, nil
+	}
+
+	if run == 1 {
+		h.buf[p] = valOp(value, 1)
+	} else {
+		last := first + run - 1
+
+		seq := make([]byte, 0, 5)
+		if i != first {
+			if isVal {
+				seq = append(seq, valOp(old, i-first))
+			} else {
+				seq = appendZeroRun(seq, i-first)
+			}
+		}
+		seq = append(seq, valOp(value, 1))
+		if i != last {
+			if isVal {
+				seq = append(seq, valOp(old, last-i))
+			} else {
+				seq = appendZeroRun(seq, last-i)
+			}
+		}
+
+		if delta := len(seq) - size; delta != 0 && len(h.buf)+delta > hllSparseMaxBytes {
+			if err := h.toDense(); err != nil {
+				return false, err
+			}
+			return h.set(i, value)
+		}
+
+		h.buf = slices.Replace(h.buf, p, p+size, seq...)
+	}
+
+	h.mergeRuns(prev)
+	h.invalidateCache()
+	return true, nil
+}
+
+// mergeRuns merges neighbouring VAL opcodes of the same value, looking at
+// the few opcodes from p on that a change may have split.
+func (h *HLL) mergeRuns(p int) {
+	for scan := 5; p < len(h.buf) && scan > 0; scan-- {
+		op := h.buf[p]
+		switch {
+		case op&0xc0 == 0x40:
+			p += 2
+			continue
+		case op&0xc0 == 0x00:
+			p++
+			continue
+		}
+
+		if p+1 < len(h.buf) && h.buf[p+1]&0x80 != 0 {
+			v1, l1, _, _ := sparseOp(h.buf[p:])
+			v2, l2, _, _ := sparseOp(h.buf[p+1:])
+
+			if v1 == v2 && l1+l2 <= hllSparseMaxVal {
+				h.buf[p+1] = valOp(v1, l1+l2)
+				h.buf = slices.Delete(h.buf, p, p+1)
+				continue
+			}
+		}
+		p++
+	}
+}
+
+// toDense converts h to the dense encoding, keeping its header.
+func (h *HLL) toDense() error {
+	registers, err := h.registers()
+	if err != nil {
+		return err
+	}
+
+	buf := make([]byte, hllDenseSize)
+	copy(buf, h.buf[:hllHeader])
+	buf[4] = hllDense
+
+	for i, value := range registers {
+		setDenseRegister(buf[hllHeader:], i, value)
+	}
+
+	h.buf = buf
+	return nil
+}
+
+// registers returns the value of every register.
+func (h *HLL) registers() (*[hllRegisters]uint8, error) {
+	var registers [hllRegisters]uint8
+
+	if h.dense() {
+		data := h.buf[hllHeader:]
+		for i := range registers {
+			registers[i] = denseRegister(data, i)
+		}
+		return &registers, nil
+	}
+
+	i := 0
+	err := h.runs(func(value uint8, run int) {
+		for ; run > 0; run-- {
+			registers[i] = value
+			i++
+		}
+	})
+	return &registers, err
+}
+
+// runs calls fn with every run of registers of a sparse HyperLogLog, and
+// fails when the runs do not cover exactly every register.
+func (h *HLL) runs(fn func(value uint8, run int)) error {
+	n := 0
+	for p := hllHeader; p < len(h.buf); {
+		value, run, size, ok := sparseOp(h.buf[p:])
+		if !ok || n+run > hllRegisters {
+			return ErrCorruptHLL
+		}
+
+		fn(value, run)
+		n += run
+		p += size
+	}
+
+	if n != hllRegisters {
+		return ErrCorruptHLL
+	}
+	return nil
+}
+
+// Merge raises every register of h to the largest value it has in others,
+// so that h estimates the union of all of them. Like PFMERGE in Redis, the
+// result is dense when any of them is.
+func (h *HLL) Merge(others ...*HLL) error {
+	var union [hllRegisters]uint8
+	dense := h.dense()
+
+	for _, other := range others {
+		registers, err := other.registers()
+		if err != nil {
+			return err
+		}
+
+		for i, value := range registers {
+			union[i] = max(union[i], value)
+		}
+		dense = dense || other.dense()
+	}
+
+	if dense && !h.dense() {
+		if err := h.toDense(); err != nil {
+			return err
+		}
+	}
+
+	for i, value := range union {
+		if value == 0 {
+			continue
+		}
+		if _, err := h.set(i, value); err != nil {
+			return err
+		}
+	}
+
+	h.invalidateCache()
+	return nil
+}
+
+// Count returns the estimated number of distinct elements added. The result
+// is cached in the header until the registers change.
+func (h *HLL) Count() (uint64, error) {
+	if h.buf[hllHeader-1]&hllCardStale == 0 {
+		return binary.LittleEndian.Uint64(h.buf[8:hllHeader]), nil
+	}
+
+	// registers are 6 bits wide, so corrupt ones may hold more than Add
+	// ever sets
+	var histogram [1 << hllBits]int
+
+	if h.dense() {
+		data := h.buf[hllHeader:]
+		for i := range hllRegisters {
+			histogram[denseRegister(data, i)]++
+		}
+	} else {
+		err := h.runs(func(value uint8, run int) {
+			histogram[value] += run
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	card := hllEstimate(&histogram)
+	binary.LittleEndian.PutUint64(h.buf[8:hllHeader], card)
+	return card, nil
+}
+
+// CountUnion returns the estimated number of distinct elements added to any
+// of hlls, without changing them.
+func CountUnion(hlls []*HLL) (uint64, error) {
+	var union [hllRegisters]uint8
+	for _, h := range hlls {
+		registers, err := h.registers()
+		if err != nil {
+			return 0, err
+		}
+
+		for i, value := range registers {
+			union[i] = max(union[i], value)
+		}
+	}
+
+	var histogram [1 << hllBits]int
+	for _, value := range union {
+		histogram[value]++
+	}
+	return hllEstimate(&histogram), nil
+}
+
+// hllEstimate is the estimator of Otmar Ertl's "New cardinality estimation
+// algorithms for HyperLogLog sketches", as Redis uses, over a histogram of
+// register values.
+func hllEstimate(histogram *[1 << hllBits]int) uint64 {
+	m := float64(hllRegisters)
+	z := m * hllTau((m-float64(histogram[hllQ+1]))/m)
+	for j := hllQ; j >= 1; j-- {
+		z += float64(histogram[j])
+		z *= 0.5
+	}
+	z += m * hllSigma(float64(histogram[0])/m)
+
+	return uint64(math.Round(hllAlphaInf * m * m / z))
+}
+
+func hllSigma(x float64) float64 {
+	if x == 1 {
+		return math.Inf(1)
+	}
+
+	y, z := 1.0, x
+	for {
+		x *= x
+		prev := z
+		z += x * y
+		y += y
+		if prev == z {
+			return z
+		}
+	}
+}
+
+func hllTau(x float64) float64 {
+	if x == 0 || x == 1 {
+		return 0
+	}
+
+	y, z := 1.0, 1-x
+	for {
+		x = math.Sqrt(x)
+		prev := z
+		y *= 0.5
+		z -= math.Pow(1-x, 2) * y
+		if prev == z {
+			return z / 3
+		}
+	}
+}
+
+func denseRegister(data []byte, i int) uint8 {
+	b, fb := i*hllBits/8, uint(i*hllBits%8)
+
+	value := uint(data[b]) >> fb
+	if b+1 < len(data) {
+		value |= uint(data[b+1]) << (8 - fb)
+	}
+	return uint8(value) & hllRegisterMax
+}
+
+func setDenseRegister(data []byte, i int, value uint8) {
+	b, fb := i*hllBits/8, uint(i*hllBits%8)
+
+	data[b] &^= hllRegisterMax << fb
+	data[b] |= value << fb
+	if b+1 < len(data) {
+		data[b+1] &^= hllRegisterMax >> (8 - fb)
+		data[b+1] |= value >> (8 - fb)
+	}
+}
+
+// sparseOp decodes the opcode at the start of data into the value of its
+// run of registers, the length of the run and the size of the opcode.
+func sparseOp(data []byte) (value uint8, run int, size int, ok bool) {
+	op := data[0]
+	switch {
+	case op&0xc0 == 0x00:
+		return 0, int(op&0x3f) + 1, 1, true
+	case op&0xc0 == 0x40:
+		if len(data) < 2 {
+			return 0, 0, 0, false
+		}
+		return 0, (int(op&0x3f)<<8 | int(data[1])) + 1, 2, true
+	default:
+		return (op>>2)&0x1f + 1, int(op&0x03) + 1, 1, true
+	}
+}
+
+// valOp encodes a run of n registers holding value, n being at most 4.
+func valOp(value uint8, n int) byte {
+	return 0x80 | (value-1)<<2 | byte(n-1)
+}
+
+// appendZeroRun encodes a run of n registers holding zero.
+func appendZeroRun(buf []byte, n int) []byte {
+	if n > hllSparseMaxZero {
+		return append(buf, 0x40|byte((n-1)>>8), byte(n-1))
+	}
+	return append(buf, byte(n-1))
+}
+
+// murmurHash64A is the 64 bit MurmurHash2 Redis hashes elements with.
+func murmurHash64A(key []byte, seed uint64) uint64 {
+	const m = 0xc6a4a7935bd1e995
+	const r = 47
+
+	h := seed ^ uint64(len(key))*m
+
+	for len(key) >= 8 {
+		k := binary.LittleEndian.Uint64(key)
+		k *= m
+		k ^= k >> r
+		k *= m
+
+		h ^= k
+		h *= m
+		key = key[8:]
+	}
+
+	if len(key) > 0 {
+		for i := len(key) - 1; i >= 0; i-- {
+			h ^= uint64(key[i]) << (8 * i)
+		}
+		h *= m
+	}
+
+	h ^= h >> r
+	h *= m
+	h ^= h >> r
+	return h
+}
@@ -0,0 +1,298 @@
+package types
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// hllHeaderOf builds a header of the given encoding holding card, with the
+// top bit of its last byte set when stale.
+func hllHeaderOf(encoding byte, card uint64, stale bool) string {
+	header := []byte{'H', 'Y', 'L', 'L', encoding, 0, 0, 0}
+	for i := range 8 {
+		header = append(header, byte(card>>(8*i)))
+	}
+	if stale {
+		header[hllHeader-1] |= hllCardStale
+	}
+	return string(header)
+}
+
+func TestHLLRoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  uint64
+		// cached is the value once Count has cached the cardinality
+		cached string
+	}{
+		{
+			name:  "empty, as PFADD without elements stores it",
+			value: "HYLL\x01\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x7f\xff",
+			want:  0,
+		},
+		{
+			name:  "sparse with a stale cardinality",
+			value: hllHeaderOf(hllSparse, 0, true) + "\x43\xe7\x84\x7c\x16",
+			want:  1,
+			// XZERO:1000 VAL:2,1 XZERO:15383
+			cached: hllHeaderOf(hllSparse, 1, false) + "\x43\xe7\x84\x7c\x16",
+		},
+		{
+			name:  "sparse with a cached cardinality",
+			value: hllHeaderOf(hllSparse, 42, false) + "\x7f\xff",
+			want:  42,
+		},
+		{
+			name:   "dense with every register zero",
+			value:  hllHeaderOf(hllDense, 0, true) + strings.Repeat("\x00", hllDenseSize-hllHeader),
+			want:   0,
+			cached: hllHeaderOf(hllDense, 0, false) + strings.Repeat("\x00", hllDenseSize-hllHeader),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, err := ParseHLL([]byte(tt.value))
+			if err != nil {
+				t.Fatalf("ParseHLL() error = %v", err)
+			}
+
+			got, err := h.Count()
+			if err != nil {
+				t.Fatalf("Count() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Count() = %d, want %d", got, tt.want)
+			}
+
+			want := tt.cached
+			if want == "" {
+				want = tt.value
+			}
+			if string(h.Bytes()) != want {
+				t.Errorf("Bytes() = %q, want %q", h.Bytes(), want)
+			}
+		})
+	}
+}
+
+func TestNewHLL(t *testing.T) {
+	want := "HYLL\x01\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x7f\xff"
+
+	if got := NewHLL().Bytes(); string(got) != want {
+		t.Errorf("NewHLL().Bytes() = %q, want %q", got, want)
+	}
+}
+
+func TestHLLCount(t *testing.T) {
+	tests := []struct {
+		name     string
+		elements []string
+		want     uint64
+	}{
+		{"none", nil, 0},
+		{"distinct", []string{"a", "b", "c", "d", "e", "f", "g"}, 7},
+		{"repeated", []string{"foo", "bar", "zap", "zap", "zap", "foo", "bar"}, 3},
+		{"empty string", []string{""}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewHLL()
+			for _, element := range tt.elements {
+				if _, err := h.Add([]byte(element)); err != nil {
+					t.Fatalf("Add(%q) error = %v", element, err)
+				}
+			}
+
+			got, err := h.Count()
+			if err != nil {
+				t.Fatalf("Count() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Count() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHLLCountCache(t *testing.T) {
+	h := NewHLL()
+	add := func(elements ...string) {
+		for _, element := range elements {
+			if _, err := h.Add([]byte(element)); err != nil {
+				t.Fatalf("Add(%q) error = %v", element, err)
+			}
+		}
+	}
+	stale := func() bool {
+		return h.Bytes()[hllHeader-1]&hllCardStale != 0
+	}
+
+	add("a", "b", "c")
+	if !stale() {
+		t.Fatal("cardinality cached after adding new elements")
+	}
+
+	if _, err := h.Count(); err != nil {
+		t.Fatalf("Count() error = %v", err)
+	}
+	if stale() {
+		t.Fatal("cardinality not cached by Count")
+	}
+
+	add("a", "b", "c")
+	if stale() {
+		t.Error("cardinality invalidated by adding known elements")
+	}
+
+	add("1", "2", "3")
+	if !stale() {
+		t.Error("cardinality still cached after adding new elements")
+	}
+}
+
+func TestHLLSparseMatchesDense(t *testing.T) {
+	for _, n := range []int{10, 100, 1000, 5000, 50000} {
+		t.Run(fmt.Sprint(n), func(t *testing.T) {
+			sparse := NewHLL()
+			dense := NewHLL()
+			if err := dense.toDense(); err != nil {
+				t.Fatalf("toDense() error = %v", err)
+			}
+
+			for i := range n {
+				element := []byte(fmt.Sprint("element:", i))
+
+				s, err := sparse.Add(element)
+				if err != nil {
+					t.Fatalf("Add(%q) to sparse error = %v", element, err)
+				}
+				d, _ := dense.Add(element)
+				if s != d {
+					t.Fatalf("Add(%q) = %t to sparse, %t to dense", element, s, d)
+				}
+			}
+
+			if len(sparse.Bytes()) > hllSparseMaxBytes && !sparse.dense() {
+				t.Errorf("sparse grew to %d bytes", len(sparse.Bytes()))
+			}
+
+			got, err := sparse.registers()
+			if err != nil {
+				t.Fatalf("registers() error = %v", err)
+			}
+			want, _ := dense.registers()
+			if *got != *want {
+				t.Error("sparse and dense registers differ")
+			}
+
+			if err := sparse.toDense(); err != nil {
+				t.Fatalf("toDense() error = %v", err)
+			}
+			if _, err := sparse.Count(); err != nil {
+				t.Fatalf("Count() error = %v", err)
+			}
+			if _, err := dense.Count(); err != nil {
+				t.Fatalf("Count() error = %v", err)
+			}
+			if !bytes.Equal(sparse.Bytes(), dense.Bytes()) {
+				t.Error("sparse converted to dense differs from dense")
+			}
+		})
+	}
+}
+
+func TestHLLMerge(t *testing.T) {
+	add := func(elements ...string) *HLL {
+		h := NewHLL()
+		for _, element := range elements {
+			h.Add([]byte(element))
+		}
+		return h
+	}
+
+	union := NewHLL()
+	err := union.Merge(add("foo", "bar", "zap", "a"), add("a", "b", "c", "foo"))
+	if err != nil {
+		t.Fatalf("Merge() error = %v", err)
+	}
+
+	if got, _ := union.Count(); got != 6 {
+		t.Errorf("Count() = %d, want 6", got)
+	}
+}
+
+func TestParseHLLErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+	}{
+		{"too short", "HYLL"},
+		{"broken magic", "0123" + hllHeaderOf(hllSparse, 0, false)[4:] + "\x7f\xff"},
+		{"invalid encoding", "HYLLx" + hllHeaderOf(hllSparse, 0, false)[5:] + "\x7f\xff"},
+		{"dense of the wrong length", hllHeaderOf(hllDense, 0, false) + "\x7f\xff"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseHLL([]byte(tt.value)); !errors.Is(err, ErrNotHLL) {
+				t.Errorf("ParseHLL() error = %v, want %v", err, ErrNotHLL)
+			}
+		})
+	}
+}
+
+func TestHLLCorrupt(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+	}{
+		{"additional data at the tail", hllHeaderOf(hllSparse, 0, true) + "\x7f\xffhello"},
+		{"runs too short", hllHeaderOf(hllSparse, 0, true) + "\x7f\xfe"},
+		{"runs too long", hllHeaderOf(hllSparse, 0, true) + "\x7f\xff\x00"},
+		{"truncated XZERO", hllHeaderOf(hllSparse, 0, true) + "\x7f\xfe\x40"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, err := ParseHLL([]byte(tt.value))
+			if err != nil {
+				t.Fatalf("ParseHLL() error = %v", err)
+			}
+
+			if _, err := h.Count(); !errors.Is(err, ErrCorruptHLL) {
+				t.Errorf("Count() error = %v, want %v", err, ErrCorruptHLL)
+			}
+			if err := NewHLL().Merge(h); !errors.Is(err, ErrCorruptHLL) {
+				t.Errorf("Merge() error = %v, want %v", err, ErrCorruptHLL)
+			}
+		})
+	}
+}
+
+func TestHLLCountRegistersAboveQ(t *testing.T) {
+	// Add never sets a register above hllQ+1, but a dense value written
+	// with SET may hold anything up to what 6 bits fit
+	for _, register := range []byte{hllQ + 1, hllQ + 2, 63} {
+		t.Run(fmt.Sprint(register), func(t *testing.T) {
+			data := make([]byte, hllDenseSize-hllHeader)
+			for i := range hllRegisters {
+				setDenseRegister(data, i, register)
+			}
+
+			h, err := ParseHLL([]byte(hllHeaderOf(hllDense, 0, true) + string(data)))
+			if err != nil {
+				t.Fatalf("ParseHLL() error = %v", err)
+			}
+
+			if _, err := h.Count(); err != nil {
+				t.Errorf("Count() error = %v", err)
+			}
+		})
+	}
+}
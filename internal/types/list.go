@@ -0,0 +1,215 @@
+package types
+
+import "slices"
+
+// List is a sequence of strings that grows and shrinks cheaply at both ends.
+// Its elements live in items[head:], leaving room in front for pushes to the
+// left.
+type List struct {
+	items []string
+	head  int
+}
+
+func NewList() *List {
+	return &List{}
+}
+
+func (l *List) Clone() *List {
+	return &List{items: slices.Clone(l.Values())}
+}
+
+func (l *List) Len() int {
+	return len(l.items) - l.head
+}
+
+// Values returns the elements of the list. The slice is shared with the list
+// and is only valid until it is modified.
+func (l *List) Values() []string {
+	return l.items[l.head:]
+}
+
+// PushLeft inserts values at the head one after the other, so the last value
+// ends up first.
+func (l *List) PushLeft(values ...string) {
+	if l.head < len(values) {
+		room := max(l.Len(), len(values), 8)
+		items := make([]string, room+l.Len(), room+cap(l.items)-l.head)
+		copy(items[room:], l.Values())
+		l.items, l.head = items, room
+	}
+
+	for _, value := range values {
+		l.head--
+		l.items[l.head] = value
+	}
+}
+
+func (l *List) PushRight(values ...string) {
+	// growing through append would carry the popped prefix along, so a queue
+	// that never empties would grow forever
+	if l.head > 0 && len(l.items)+len(values) > cap(l.items) {
+		items := make([]string, l.Len(), max(2*(l.Len()+len(values)), 8))
+		copy(items, l.Values())
+		l.items, l.head = items, 0
+	}
+
+	l.items = append(l.items, values...)
+}
+
+func (l *List) PopLeft() (string, bool) {
+	if l.Len() == 0 {
+		return "", false
+	}
+
+	value := l.items[l.head]
+	l.items[l.head] = ""
+	l.head++
+
+	if l.Len() == 0 {
+		l.items, l.head = l.items[:0], 0
+	}
+	return value, true
+}
+
+func (l *List) PopRight() (string, bool) {
+	if l.Len() == 0 {
+		return "", false
+	}
+
+	value := l.items[len(l.items)-1]
+	l.items[len(l.items)-1] = ""
+	l.items = l.items[:len(l.items)-1]
+
+	if l.Len() == 0 {
+		l.items, l.head = l.items[:0], 0
+	}
+	return value, true
+}
+
+// index turns a possibly negative index into a position in Values.
+func (l *List) index(i int) (int, bool) {
+	if i < 0 {
+		i += l.Len()
+	}
+	return i, i >= 0 && i < l.Len()
+}
+
+// Index returns the element at i, counting from the tail when i is negative.
+func (l *List) Index(i int) (string, bool) {
+	i, ok := l.index(i)
+	if !ok {
+		return "", false
+	}
+	return l.Values()[i], true
+}
+
+func (l *List) Set(i int, value string) bool {
+	i, ok := l.index(i)
+	if !ok {
+		return false
+	}
+	l.Values()[i] = value
+	return true
+}
+
+// Range returns the elements between start and end inclusive, with the same
+// handling of negative and out of range indexes as LRANGE.
+func (l *List) Range(start int, end int) []string {
+	start, end, ok := NormalizeRange(start, end, l.Len())
+	if !ok {
+		return []string{}
+	}
+	return slices.Clone(l.Values()[start : end+1])
+}
+
+// Trim keeps only the elements between start and end inclusive.
+func (l *List) Trim(start int, end int) {
+	start, end, ok := NormalizeRange(start, end, l.Len())
+	if !ok {
+		l.items, l.head = nil, 0
+		return
+	}
+
+	l.items = slices.Clone(l.Values()[start : end+1])
+	l.head = 0
+}
+
+// Insert adds value next to the first occurrence of pivot and reports whether
+// pivot was found.
+func (l *List) Insert(pivot string, value string, before bool) bool {
+	i := slices.Index(l.Values(), pivot)
+	if i == -1 {
+		return false
+	}
+
+	if !before {
+		i++
+	}
+
+	l.items = slices.Insert(l.items, l.head+i, value)
+	return true
+}
+
+// Remove deletes up to count occurrences of value, starting from the tail
+// when count is negative, and all of them when count is 0. It returns how many
+// were removed.
+func (l *List) Remove(count int, value string) int {
+	values := l.Values()
+	removed := 0
+	limit := count
+	if limit < 0 {
+		limit = -limit
+	}
+
+	keep := make([]bool, len(values))
+	for i := range values {
+		keep[i] = true
+	}
+
+	for n := 0; n < len(values); n++ {
+		i := n
+		if count < 0 {
+			i = len(values) - 1 - n
+		}
+
+		if values[i] == value && (limit == 0 || removed < limit) {
+			keep[i] = false
+			removed++
+		}
+	}
+
+	if removed == 0 {
+		return 0
+	}
+
+	items := make([]string, 0, len(values)-removed)
+	for i, v := range values {
+		if keep[i] {
+			items = append(items, v)
+		}
+	}
+	l.items, l.head = items, 0
+
+	return removed
+}
+
+// NormalizeRange resolves the inclusive range between start and end over a
+// sequence of length n the way Redis range commands do: negative indexes
+// count from the end and out of range indexes are clamped. It reports false
+// when the range is empty.
+func NormalizeRange(start int, end int, n int) (int, int, bool) {
+	if start < 0 {
+		start = max(n+start, 0)
+	}
+	if end < 0 {
+		end = n + end
+	}
+	if end >= n {
+		end = n - 1
+	}
+
+	if start > end || start >= n {
+		return 0, 0, false
+	}
+	return start, end, true
+}
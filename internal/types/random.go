@@ -0,0 +1,81 @@
+package types
+
+import "math/rand/v2"
+
+// randomDrawFactor is how many times more keys than asked for a map needs
+// for RandomKeys to draw them one at a time, as Redis does for SRANDMEMBER.
+const randomDrawFactor = 3
+
+// randomSample is how many keys randomKey picks one from, as
+// GETFAIR_NUM_ENTRIES is in Redis.
+const randomSample = 15
+
+// randomKey returns a key of m. Maps are iterated from a random position, but
+// the key found there is far likelier to be some keys than others, so like
+// dictGetFairRandomKey in Redis it picks one of the keys that follow it.
+func randomKey[V any](m map[string]V) string {
+	var sample [randomSample]string
+	n := 0
+
+	for key := range m {
+		sample[n] = key
+		n++
+		if n == len(sample) {
+			break
+		}
+	}
+
+	return sample[rand.IntN(n)]
+}
+
+// RandomKeys returns n keys of m picked at random. They may repeat when
+// repeat is set, otherwise they are distinct and there are at most len(m) of
+// them.
+func RandomKeys[V any](m map[string]V, n int, repeat bool) []string {
+	if len(m) == 0 || n <= 0 {
+		return []string{}
+	}
+
+	if !repeat {
+		n = min(n, len(m))
+	}
+
+	// a few keys out of many are drawn one at a time, which costs nothing in
+	// the size of the map
+	if n < len(m)/randomDrawFactor {
+		keys := make([]string, 0, n)
+		seen := make(map[string]bool, n)
+
+		for len(keys) < n {
+			key := randomKey(m)
+			if !repeat {
+				if seen[key] {
+					continue
+				}
+				seen[key] = true
+			}
+			keys = append(keys, key)
+		}
+		return keys
+	}
+
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+
+	if repeat {
+		picked := make([]string, n)
+		for i := range picked {
+			picked[i] = keys[rand.IntN(len(keys))]
+		}
+		return picked
+	}
+
+	// a partial Fisher-Yates shuffle only moves the keys it picks
+	for i := range n {
+		j := i + rand.IntN(len(keys)-i)
+		keys[i], keys[j] = keys[j], keys[i]
+	}
+	return keys[:n]
+}
@@ -0,0 +1,91 @@
+package types
+
+import (
+	"maps"
+	"slices"
+)
+
+// Set is an unordered collection of distinct strings.
+type Set map[string]struct{}
+
+func NewSet() Set {
+	return Set{}
+}
+
+func (s Set) Clone() Set {
+	return maps.Clone(s)
+}
+
+func (s Set) Members() []string {
+	members := make([]string, 0, len(s))
+	for member := range s {
+		members = append(members, member)
+	}
+	return members
+}
+
+// Inter returns the members found in every one of sets, stopping once it
+// has found limit of them unless limit is 0.
+func Inter(sets []Set, limit int) Set {
+	result := NewSet()
+	if len(sets) == 0 {
+		return result
+	}
+
+	// checking the members of the smallest set does the least work
+	sets = slices.Clone(sets)
+	slices.SortFunc(sets, func(a, b Set) int { return len(a) - len(b) })
+
+	for member := range sets[0] {
+		found := true
+		for _, set := range sets[1:] {
+			if _, ok := set[member]; !ok {
+				found = false
+				break
+			}
+		}
+
+		if found {
+			result[member] = struct{}{}
+			if limit > 0 && len(result) >= limit {
+				break
+			}
+		}
+	}
+
+	return result
+}
+
+func Union(sets []Set) Set {
+	result := NewSet()
+	for _, set := range sets {
+		for member := range set {
+			result[member] = struct{}{}
+		}
+	}
+	return result
+}
+
+// Diff returns the members of the first set that are in none of the others.
+func Diff(sets []Set) Set {
+	result := NewSet()
+	if len(sets) == 0 {
+		return result
+	}
+
+	for member := range sets[0] {
+		found := false
+		for _, set := range sets[1:] {
+			if _, ok := set[member]; ok {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			result[member] = struct{}{}
+		}
+	}
+
+	return result
+}
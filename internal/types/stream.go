@@ -0,0 +1,558 @@
+package types
+
+import (
+	"cmp"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// StreamID identifies a stream entry by the unix time in milliseconds it was
+// added at and a sequence number among the entries of that millisecond.
+type StreamID struct {
+	Ms  uint64
+	Seq uint64
+}
+
+// ParseStreamID parses an ID of the form <ms>-<seq>. A bare <ms> is accepted
+// as well and gets defaultSeq as its sequence number.
+func ParseStreamID(s string, defaultSeq uint64) (StreamID, bool) {
+	ms, seq, hasSeq := strings.Cut(s, "-")
+
+	id := StreamID{Seq: defaultSeq}
+
+	var err error
+	if id.Ms, err = strconv.ParseUint(ms, 10, 64); err != nil {
+		return StreamID{}, false
+	}
+
+	if hasSeq {
+		if id.Seq, err = strconv.ParseUint(seq, 10, 64); err != nil {
+			return StreamID{}, false
+		}
+	}
+
+	return id, true
+}
+
+func (id StreamID) String() string {
+	return strconv.FormatUint(id.Ms, 10) + "-" + strconv.FormatUint(id.Seq, 10)
+}
+
+func (id StreamID) Compare(other StreamID) int {
+	if c := cmp.Compare(id.Ms, other.Ms); c != 0 {
+		return c
+	}
+	return cmp.Compare(id.Seq, other.Seq)
+}
+
+// Next returns the smallest ID greater than id, and false when id is the
+// largest possible ID.
+func (id StreamID) Next() (StreamID, bool) {
+	switch {
+	case id.Seq < math.MaxUint64:
+		return StreamID{id.Ms, id.Seq + 1}, true
+	case id.Ms < math.MaxUint64:
+		return StreamID{id.Ms + 1, 0}, true
+	}
+	return id, false
+}
+
+// Prev returns the largest ID smaller than id, and false when id is 0-0.
+func (id StreamID) Prev() (StreamID, bool) {
+	switch {
+	case id.Seq > 0:
+		return StreamID{id.Ms, id.Seq - 1}, true
+	case id.Ms > 0:
+		return StreamID{id.Ms - 1, math.MaxUint64}, true
+	}
+	return id, false
+}
+
+// streamNodeMaxEntries is how many entries Redis packs in a node of a stream.
+// Approximate trimming only removes whole nodes, so it removes entries in
+// multiples of it here as well.
+const streamNodeMaxEntries = 100
+
+// Stream is a log of entries ordered by ID. LastID is the ID of the last
+// entry ever added, which new entries must be greater than, and MaxDeletedID
+// the greatest ID deleted from the middle of the log.
+type Stream struct {
+	Entries      []StreamEntry
+	LastID       StreamID
+	MaxDeletedID StreamID
+	EntriesAdded int64
+	Groups       map[string]*ConsumerGroup
+}
+
+type StreamEntry struct {
+	ID    StreamID
+	Items []StreamItem
+}
+
+type StreamItem struct {
+	Key   string
+	Value string
+}
+
+func NewStream() *Stream {
+	return &Stream{Groups: make(map[string]*ConsumerGroup)}
+}
+
+// Clone returns a copy of the stream along with its consumer groups. Entries
+// are never modified once added, so the copy shares their items.
+func (s *Stream) Clone() *Stream {
+	clone := &Stream{
+		Entries:      slices.Clone(s.Entries),
+		LastID:       s.LastID,
+		MaxDeletedID: s.MaxDeletedID,
+		EntriesAdded: s.EntriesAdded,
+		Groups:       make(map[string]*ConsumerGroup, len(s.Groups)),
+	}
+
+	for name, group := range s.Groups {
+		g := NewConsumerGroup(group.Name, group.LastID, group.EntriesRead)
+
+		for consumerName, consumer := range group.Consumers {
+			g.Consumers[consumerName] = &Consumer{
+				Name:       consumer.Name,
+				SeenTime:   consumer.SeenTime,
+				ActiveTime: consumer.ActiveTime,
+				Pending:    NewPendingEntries(),
+			}
+		}
+
+		group.Pending.Ascend(StreamID{}, func(pe *PendingEntry) bool {
+			owner := g.Consumers[pe.Consumer.Name]
+			copied := &PendingEntry{
+				ID:            pe.ID,
+				Consumer:      owner,
+				DeliveryTime:  pe.DeliveryTime,
+				DeliveryCount: pe.DeliveryCount,
+			}
+			g.Pending.Add(copied)
+			owner.Pending.Add(copied)
+			return true
+		})
+
+		clone.Groups[name] = g
+	}
+
+	return clone
+}
+
+// Append adds an entry at the end of the stream. The caller makes sure id is
+// greater than LastID.
+func (s *Stream) Append(id StreamID, items []StreamItem) {
+	s.Entries = append(s.Entries, StreamEntry{ID: id, Items: items})
+	s.LastID = id
+	s.EntriesAdded++
+}
+
+// Entry returns the entry with the given id.
+func (s *Stream) Entry(id StreamID) (StreamEntry, bool) {
+	i, ok := sort.Find(len(s.Entries), func(i int) int {
+		return id.Compare(s.Entries[i].ID)
+	})
+	if !ok {
+		return StreamEntry{}, false
+	}
+	return s.Entries[i], true
+}
+
+// After returns up to count entries with an ID greater than id, or all of
+// them when count is 0.
+func (s *Stream) After(id StreamID, count int) []StreamEntry {
+	i := sort.Search(len(s.Entries), func(i int) bool {
+		return s.Entries[i].ID.Compare(id) > 0
+	})
+
+	entries := s.Entries[i:]
+	if count > 0 && len(entries) > count {
+		entries = entries[:count]
+	}
+	return entries
+}
+
+// Range returns the entries with an ID between start and end inclusive.
+func (s *Stream) Range(start StreamID, end StreamID) []StreamEntry {
+	i := sort.Search(len(s.Entries), func(i int) bool {
+		return s.Entries[i].ID.Compare(start) >= 0
+	})
+	j := sort.Search(len(s.Entries), func(j int) bool {
+		return s.Entries[j].ID.Compare(end) > 0
+	})
+
+	if i >= j {
+		return nil
+	}
+	return s.Entries[i:j]
+}
+
+// Delete removes the entry with the given id and reports whether it existed.
+func (s *Stream) Delete(id StreamID) bool {
+	i, ok := sort.Find(len(s.Entries), func(i int) int {
+		return id.Compare(s.Entries[i].ID)
+	})
+	if !ok {
+		return false
+	}
+
+	s.Entries = slices.Delete(s.Entries, i, i+1)
+	if id.Compare(s.MaxDeletedID) > 0 {
+		s.MaxDeletedID = id
+	}
+	return true
+}
+
+// TrimMaxLen removes the oldest entries until at most maxLen are left, and
+// returns how many it removed. When approx is set only whole nodes are
+// removed, up to limit entries unless limit is 0.
+func (s *Stream) TrimMaxLen(maxLen int, approx bool, limit int) int {
+	return s.trim(len(s.Entries)-maxLen, approx, limit)
+}
+
+// TrimMinID removes the entries with an ID lower than id, the same way
+// TrimMaxLen does.
+func (s *Stream) TrimMinID(id StreamID, approx bool, limit int) int {
+	n := sort.Search(len(s.Entries), func(i int) bool {
+		return s.Entries[i].ID.Compare(id) >= 0
+	})
+	return s.trim(n, approx, limit)
+}
+
+func (s *Stream) trim(n int, approx bool, limit int) int {
+	if approx {
+		if limit > 0 {
+			n = min(n, limit)
+		}
+		n -= n % streamNodeMaxEntries
+	}
+
+	if n <= 0 {
+		return 0
+	}
+
+	clear(s.Entries[:n])
+	s.Entries = s.Entries[n:]
+	return n
+}
+
+// hasTombstones reports whether entries from start onwards were deleted
+// from the middle of the log.
+func (s *Stream) hasTombstones(start StreamID) bool {
+	if len(s.Entries) == 0 || s.MaxDeletedID == (StreamID{}) {
+		return false
+	}
+	return start.Compare(s.MaxDeletedID) <= 0
+}
+
+// EntriesReadAt returns how many entries were added up to and including id,
+// or -1 when deleted entries make it impossible to tell.
+func (s *Stream) EntriesReadAt(id StreamID) int64 {
+	if s.EntriesAdded == 0 {
+		return 0
+	}
+
+	last := id.Compare(s.LastID)
+	if (len(s.Entries) == 0 && last <= 0) || last == 0 {
+		return s.EntriesAdded
+	}
+	if last > 0 {
+		return -1
+	}
+
+	// with no deletions past the first entry everything before it was
+	// trimmed, so the count is only known up to the first entry
+	first := s.Entries[0].ID
+	if s.MaxDeletedID == (StreamID{}) || s.MaxDeletedID.Compare(first) < 0 {
+		switch id.Compare(first) {
+		case -1:
+			return s.EntriesAdded - int64(len(s.Entries))
+		case 0:
+			return s.EntriesAdded - int64(len(s.Entries)) + 1
+		}
+	}
+	return -1
+}
+
+// Deliver moves the last delivered ID of g to id, the next entry after it,
+// keeping the count of entries it read in step.
+func (s *Stream) Deliver(g *ConsumerGroup, id StreamID) {
+	if g.EntriesRead != -1 && !s.hasTombstones(id) {
+		g.EntriesRead++
+	} else if s.EntriesAdded > 0 {
+		g.EntriesRead = s.EntriesReadAt(id)
+	}
+	g.LastID = id
+}
+
+// Lag returns how many entries g has yet to read, and false when it is not
+// known.
+func (s *Stream) Lag(g *ConsumerGroup) (int64, bool) {
+	if s.EntriesAdded == 0 {
+		return 0, true
+	}
+
+	if g.EntriesRead != -1 && !s.hasTombstones(g.LastID) {
+		return s.EntriesAdded - g.EntriesRead, true
+	}
+
+	read := s.EntriesReadAt(g.LastID)
+	if read == -1 {
+		return 0, false
+	}
+	return s.EntriesAdded - read, true
+}
+
+// ConsumerGroup tracks the entries of a stream delivered to its consumers.
+// LastID is the last entry delivered, and Pending holds the entries that
+// were delivered but not acknowledged yet. EntriesRead counts the entries
+// delivered so far, or is -1 when it is not known.
+type ConsumerGroup struct {
+	Name        string
+	LastID      StreamID
+	EntriesRead int64
+	Pending     *PendingEntries
+	Consumers   map[string]*Consumer
+}
+
+// Consumer is a member of a consumer group. SeenTime is when it last tried
+// to read or claim entries and ActiveTime when it last got some, both in unix
+// milliseconds with ActiveTime -1 until then.
+type Consumer struct {
+	Name       string
+	SeenTime   int64
+	ActiveTime int64
+	Pending    *PendingEntries
+}
+
+// PendingEntry is an entry delivered to a consumer that has not acknowledged
+// it yet.
+type PendingEntry struct {
+	ID            StreamID
+	Consumer      *Consumer
+	DeliveryTime  int64
+	DeliveryCount int64
+}
+
+func NewConsumerGroup(name string, lastID StreamID, entriesRead int64) *ConsumerGroup {
+	return &ConsumerGroup{
+		Name:        name,
+		LastID:      lastID,
+		EntriesRead: entriesRead,
+		Pending:     NewPendingEntries(),
+		Consumers:   make(map[string]*Consumer),
+	}
+}
+
+// CreateConsumer returns the consumer called name, creating it when it does
+// not exist yet, and reports whether it was created.
+func (g *ConsumerGroup) CreateConsumer(name string, now int64) (*Consumer, bool) {
+	if consumer, ok := g.Consumers[name]; ok {
+		return consumer, false
+	}
+
+	consumer := &Consumer{
+		Name:       name,
+		SeenTime:   now,
+		ActiveTime: -1,
+		Pending:    NewPendingEntries(),
+	}
+	g.Consumers[name] = consumer
+
+	return consumer, true
+}
+
+// DeleteConsumer removes the consumer called name along with its pending
+// entries, and returns how many of them there were.
+func (g *ConsumerGroup) DeleteConsumer(name string) (int, bool) {
+	consumer, ok := g.Consumers[name]
+	if !ok {
+		return 0, false
+	}
+
+	consumer.Pending.Ascend(StreamID{}, func(pe *PendingEntry) bool {
+		g.Pending.Delete(pe.ID)
+		return true
+	})
+	delete(g.Consumers, name)
+
+	return consumer.Pending.Len(), true
+}
+
+// Assign makes consumer the owner of the pending entry id, which is added to
+// the pending entries when it is not there yet.
+func (g *ConsumerGroup) Assign(id StreamID, consumer *Consumer) *PendingEntry {
+	pe, ok := g.Pending.Get(id)
+	if !ok {
+		pe = &PendingEntry{ID: id}
+		g.Pending.Add(pe)
+	} else {
+		pe.Consumer.Pending.Delete(id)
+	}
+
+	pe.Consumer = consumer
+	consumer.Pending.Add(pe)
+
+	return pe
+}
+
+// Ack removes id from the pending entries and reports whether it was there.
+func (g *ConsumerGroup) Ack(id StreamID) bool {
+	pe, ok := g.Pending.Get(id)
+	if !ok {
+		return false
+	}
+
+	pe.Consumer.Pending.Delete(id)
+	g.Pending.Delete(id)
+
+	return true
+}
+
+// PendingEntries holds pending entries by ID. A map finds an entry and a
+// skiplist like the one of sorted sets keeps them in order, so that adding or
+// acknowledging one takes logarithmic time wherever it is in the list.
+type PendingEntries struct {
+	entries map[StreamID]*pelNode
+	header  *pelNode
+	tail    *pelNode
+	level   int
+}
+
+type pelNode struct {
+	entry    *PendingEntry
+	backward *pelNode
+	forward  []*pelNode
+}
+
+func NewPendingEntries() *PendingEntries {
+	return &PendingEntries{
+		entries: make(map[StreamID]*pelNode),
+		header:  &pelNode{forward: make([]*pelNode, zskiplistMaxLevel)},
+		level:   1,
+	}
+}
+
+func (p *PendingEntries) Len() int {
+	return len(p.entries)
+}
+
+func (p *PendingEntries) Get(id StreamID) (*PendingEntry, bool) {
+	x, ok := p.entries[id]
+	if !ok {
+		return nil, false
+	}
+	return x.entry, true
+}
+
+// First returns the entry with the lowest ID, or nil when there are none.
+func (p *PendingEntries) First() *PendingEntry {
+	if x := p.header.forward[0]; x != nil {
+		return x.entry
+	}
+	return nil
+}
+
+// Last returns the entry with the highest ID, or nil when there are none.
+func (p *PendingEntries) Last() *PendingEntry {
+	if p.tail != nil {
+		return p.tail.entry
+	}
+	return nil
+}
+
+// Add adds pe, replacing the entry with the same ID if there is one.
+func (p *PendingEntries) Add(pe *PendingEntry) {
+	if x, ok := p.entries[pe.ID]; ok {
+		x.entry = pe
+		return
+	}
+
+	var update [zskiplistMaxLevel]*pelNode
+	x := p.header
+	for i := p.level - 1; i >= 0; i-- {
+		for x.forward[i] != nil && x.forward[i].entry.ID.Compare(pe.ID) < 0 {
+			x = x.forward[i]
+		}
+		update[i] = x
+	}
+
+	level := randomLevel()
+	if level > p.level {
+		for i := p.level; i < level; i++ {
+			update[i] = p.header
+		}
+		p.level = level
+	}
+
+	x = &pelNode{entry: pe, forward: make([]*pelNode, level)}
+	for i := range level {
+		x.forward[i] = update[i].forward[i]
+		update[i].forward[i] = x
+	}
+
+	if update[0] != p.header {
+		x.backward = update[0]
+	}
+	if x.forward[0] != nil {
+		x.forward[0].backward = x
+	} else {
+		p.tail = x
+	}
+
+	p.entries[pe.ID] = x
+}
+
+func (p *PendingEntries) Delete(id StreamID) {
+	if _, ok := p.entries[id]; !ok {
+		return
+	}
+	delete(p.entries, id)
+
+	var update [zskiplistMaxLevel]*pelNode
+	x := p.header
+	for i := p.level - 1; i >= 0; i-- {
+		for x.forward[i] != nil && x.forward[i].entry.ID.Compare(id) < 0 {
+			x = x.forward[i]
+		}
+		update[i] = x
+	}
+
+	x = x.forward[0]
+	for i := range p.level {
+		if update[i].forward[i] == x {
+			update[i].forward[i] = x.forward[i]
+		}
+	}
+
+	if x.forward[0] != nil {
+		x.forward[0].backward = x.backward
+	} else {
+		p.tail = x.backward
+	}
+
+	for p.level > 1 && p.header.forward[p.level-1] == nil {
+		p.level--
+	}
+}
+
+// Ascend calls fn with the entries whose ID is start or higher in order,
+// until fn returns false. fn must not add or delete entries.
+func (p *PendingEntries) Ascend(start StreamID, fn func(pe *PendingEntry) bool) {
+	x := p.header
+	for i := p.level - 1; i >= 0; i-- {
+		for x.forward[i] != nil && x.forward[i].entry.ID.Compare(start) < 0 {
+			x = x.forward[i]
+		}
+	}
+
+	for x = x.forward[0]; x != nil; x = x.forward[0] {
+		if !fn(x.entry) {
+			return
+		}
+	}
+}
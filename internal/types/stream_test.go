@@ -0,0 +1,82 @@
+package types
+
+import (
+	"fmt"
+	"testing"
+)
+
+func pendingIDs(p *PendingEntries, start StreamID) []StreamID {
+	ids := []StreamID{}
+	p.Ascend(start, func(pe *PendingEntry) bool {
+		ids = append(ids, pe.ID)
+		return true
+	})
+	return ids
+}
+
+func TestPendingEntries(t *testing.T) {
+	p := NewPendingEntries()
+	for _, ms := range []uint64{5, 1, 3, 9, 7, 2} {
+		p.Add(&PendingEntry{ID: StreamID{Ms: ms}})
+	}
+
+	tests := []struct {
+		name   string
+		delete []uint64
+		start  StreamID
+		want   string
+		first  uint64
+		last   uint64
+	}{
+		{"in order", nil, StreamID{}, "[1-0 2-0 3-0 5-0 7-0 9-0]", 1, 9},
+		{"from an ID", nil, StreamID{Ms: 4}, "[5-0 7-0 9-0]", 1, 9},
+		{"past the last ID", nil, StreamID{Ms: 10}, "[]", 1, 9},
+		{"without the first", []uint64{1}, StreamID{}, "[2-0 3-0 5-0 7-0 9-0]", 2, 9},
+		{"without the last", []uint64{9}, StreamID{}, "[2-0 3-0 5-0 7-0]", 2, 7},
+		{"without missing IDs", []uint64{4, 9}, StreamID{}, "[2-0 3-0 5-0 7-0]", 2, 7},
+		{"without the middle", []uint64{3, 5}, StreamID{}, "[2-0 7-0]", 2, 7},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, ms := range tt.delete {
+				p.Delete(StreamID{Ms: ms})
+			}
+
+			if got := fmt.Sprint(pendingIDs(p, tt.start)); got != tt.want {
+				t.Errorf("Ascend() = %s, want %s", got, tt.want)
+			}
+			if got := p.First().ID.Ms; got != tt.first {
+				t.Errorf("First() = %d, want %d", got, tt.first)
+			}
+			if got := p.Last().ID.Ms; got != tt.last {
+				t.Errorf("Last() = %d, want %d", got, tt.last)
+			}
+		})
+	}
+
+	p.Delete(StreamID{Ms: 2})
+	p.Delete(StreamID{Ms: 7})
+	if p.Len() != 0 || p.First() != nil || p.Last() != nil {
+		t.Errorf("emptied entries have Len() = %d, First() = %v, Last() = %v", p.Len(), p.First(), p.Last())
+	}
+}
+
+func TestPendingEntriesMany(t *testing.T) {
+	p := NewPendingEntries()
+	for i := range 10000 {
+		p.Add(&PendingEntry{ID: StreamID{Ms: uint64(i)}})
+	}
+
+	// acknowledged in delivery order, as they mostly are
+	for i := range 9990 {
+		p.Delete(StreamID{Ms: uint64(i)})
+	}
+
+	if got := len(pendingIDs(p, StreamID{})); got != 10 || p.Len() != 10 {
+		t.Fatalf("Ascend() walked %d entries and Len() = %d, want 10", got, p.Len())
+	}
+	if p.First().ID.Ms != 9990 || p.Last().ID.Ms != 9999 {
+		t.Errorf("First() = %v, Last() = %v, want 9990-0 and 9999-0", p.First().ID, p.Last().ID)
+	}
+}
@@ -1,7 +1,5 @@
 package types
 
-import "time"
-
 type Command struct {
 	Name   string
 	Args   [][]byte
@@ -16,35 +14,16 @@ func NewCommand(name string, args [][]byte, length int) Command {
 	}
 }
 
+// Item is a string value. Value is owned by the store it is kept in, which
+// lets commands like SETBIT change it in place.
 type Item struct {
-	Value  string
-	Type   string
-	Expiry int64
+	Value []byte
+	Type  string
 }
 
-func NewItem(value string, vType string, exp int64) Item {
-	expiry := exp
-	if exp != -1 {
-		expiry = time.Now().UnixMilli() + exp
-	}
-
+func NewItem(value string, vType string) Item {
 	return Item{
-		Value:  value,
-		Type:   vType,
-		Expiry: expiry,
+		Value: []byte(value),
+		Type:  vType,
 	}
 }
-
-type Stream struct {
-	Entries []StreamEntry
-}
-
-type StreamEntry struct {
-	ID    string
-	Items []StreamItem
-}
-
-type StreamItem struct {
-	Key   string
-	Value string
-}
@@ -0,0 +1,410 @@
+package types
+
+import "math/rand/v2"
+
+const (
+	zskiplistMaxLevel = 32
+	zskiplistP        = 0.25
+)
+
+type ZSetMember struct {
+	Member string
+	Score  float64
+}
+
+// ZSet is a set of members ordered by score, then by member for equal
+// scores. A map gives the score of a member and a skiplist keeps the order,
+// with the span of every link counting the nodes it skips so that ranks are
+// found in logarithmic time too.
+type ZSet struct {
+	dict   map[string]float64
+	header *zskiplistNode
+	tail   *zskiplistNode
+	length int
+	level  int
+}
+
+type zskiplistNode struct {
+	member   string
+	score    float64
+	backward *zskiplistNode
+	level    []zskiplistLevel
+}
+
+type zskiplistLevel struct {
+	forward *zskiplistNode
+	span    int
+}
+
+func NewZSet() *ZSet {
+	return &ZSet{
+		dict:   make(map[string]float64),
+		header: &zskiplistNode{level: make([]zskiplistLevel, zskiplistMaxLevel)},
+		level:  1,
+	}
+}
+
+func (z *ZSet) Clone() *ZSet {
+	clone := NewZSet()
+	for x := z.header.level[0].forward; x != nil; x = x.level[0].forward {
+		clone.insert(x.score, x.member)
+		clone.dict[x.member] = x.score
+	}
+	return clone
+}
+
+func (z *ZSet) Len() int {
+	return z.length
+}
+
+func (z *ZSet) Score(member string) (float64, bool) {
+	score, ok := z.dict[member]
+	return score, ok
+}
+
+// Add sets the score of member, adding it when it is not in the set.
+func (z *ZSet) Add(member string, score float64) {
+	if old, ok := z.dict[member]; ok {
+		if old == score {
+			return
+		}
+		z.delete(old, member)
+	}
+
+	z.insert(score, member)
+	z.dict[member] = score
+}
+
+func (z *ZSet) Remove(member string) bool {
+	score, ok := z.dict[member]
+	if !ok {
+		return false
+	}
+
+	z.delete(score, member)
+	delete(z.dict, member)
+	return true
+}
+
+// Rank returns the position of member counting from 0, from the highest
+// score when rev is set.
+func (z *ZSet) Rank(member string, rev bool) (int, bool) {
+	score, ok := z.dict[member]
+	if !ok {
+		return 0, false
+	}
+
+	rank := z.rank(score, member) - 1
+	if rev {
+		rank = z.length - 1 - rank
+	}
+	return rank, true
+}
+
+// RangeByRank returns the members between the positions start and end
+// inclusive, which the caller has already clamped to the set.
+func (z *ZSet) RangeByRank(start int, end int, rev bool) []ZSetMember {
+	if start > end || start >= z.length {
+		return []ZSetMember{}
+	}
+
+	var x *zskiplistNode
+	if rev {
+		x = z.byRank(z.length - start)
+	} else {
+		x = z.byRank(start + 1)
+	}
+
+	members := make([]ZSetMember, 0, end-start+1)
+	for n := start; n <= end && x != nil; n++ {
+		members = append(members, ZSetMember{x.member, x.score})
+		if rev {
+			x = x.backward
+		} else {
+			x = x.level[0].forward
+		}
+	}
+	return members
+}
+
+// Range returns the members within r, skipping the first offset of them and
+// returning at most count unless count is negative.
+func (z *ZSet) Range(r ZRange, offset int, count int, rev bool) []ZSetMember {
+	var x *zskiplistNode
+	if rev {
+		x = z.lastInRange(r)
+	} else {
+		x = z.firstInRange(r)
+	}
+
+	// jump over the offset with the spans instead of walking it
+	if x != nil && offset > 0 {
+		rank := z.rank(x.score, x.member)
+		if rev {
+			rank -= offset
+		} else {
+			rank += offset
+		}
+
+		x = nil
+		if rank > 0 && rank <= z.length {
+			x = z.byRank(rank)
+		}
+	}
+
+	members := []ZSetMember{}
+	for x != nil && count != 0 {
+		if (rev && !r.gteMin(x.score, x.member)) || (!rev && !r.lteMax(x.score, x.member)) {
+			break
+		}
+
+		members = append(members, ZSetMember{x.member, x.score})
+		count--
+
+		if rev {
+			x = x.backward
+		} else {
+			x = x.level[0].forward
+		}
+	}
+	return members
+}
+
+// Count returns the number of members within r.
+func (z *ZSet) Count(r ZRange) int {
+	first := z.firstInRange(r)
+	if first == nil {
+		return 0
+	}
+
+	last := z.lastInRange(r)
+	return z.rank(last.score, last.member) - z.rank(first.score, first.member) + 1
+}
+
+func (z *ZSet) less(x *zskiplistNode, score float64, member string) bool {
+	return x.score < score || (x.score == score && x.member < member)
+}
+
+func randomLevel() int {
+	level := 1
+	for level < zskiplistMaxLevel && rand.Float64() < zskiplistP {
+		level++
+	}
+	return level
+}
+
+func (z *ZSet) insert(score float64, member string) {
+	var update [zskiplistMaxLevel]*zskiplistNode
+	var rank [zskiplistMaxLevel]int
+
+	x := z.header
+	for i := z.level - 1; i >= 0; i-- {
+		if i < z.level-1 {
+			rank[i] = rank[i+1]
+		}
+		for x.level[i].forward != nil && z.less(x.level[i].forward, score, member) {
+			rank[i] += x.level[i].span
+			x = x.level[i].forward
+		}
+		update[i] = x
+	}
+
+	level := randomLevel()
+	if level > z.level {
+		for i := z.level; i < level; i++ {
+			update[i] = z.header
+			update[i].level[i].span = z.length
+		}
+		z.level = level
+	}
+
+	x = &zskiplistNode{member: member, score: score, level: make([]zskiplistLevel, level)}
+	for i := 0; i < level; i++ {
+		x.level[i].forward = update[i].level[i].forward
+		update[i].level[i].forward = x
+
+		x.level[i].span = update[i].level[i].span - (rank[0] - rank[i])
+		update[i].level[i].span = rank[0] - rank[i] + 1
+	}
+
+	for i := level; i < z.level; i++ {
+		update[i].level[i].span++
+	}
+
+	if update[0] != z.header {
+		x.backward = update[0]
+	}
+	if x.level[0].forward != nil {
+		x.level[0].forward.backward = x
+	} else {
+		z.tail = x
+	}
+
+	z.length++
+}
+
+func (z *ZSet) delete(score float64, member string) {
+	var update [zskiplistMaxLevel]*zskiplistNode
+
+	x := z.header
+	for i := z.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil && z.less(x.level[i].forward, score, member) {
+			x = x.level[i].forward
+		}
+		update[i] = x
+	}
+
+	x = x.level[0].forward
+	if x == nil || x.score != score || x.member != member {
+		return
+	}
+
+	for i := 0; i < z.level; i++ {
+		if update[i].level[i].forward == x {
+			update[i].level[i].span += x.level[i].span - 1
+			update[i].level[i].forward = x.level[i].forward
+		} else {
+			update[i].level[i].span--
+		}
+	}
+
+	if x.level[0].forward != nil {
+		x.level[0].forward.backward = x.backward
+	} else {
+		z.tail = x.backward
+	}
+
+	for z.level > 1 && z.header.level[z.level-1].forward == nil {
+		z.level--
+	}
+
+	z.length--
+}
+
+// rank returns the position of the node holding member counting from 1.
+func (z *ZSet) rank(score float64, member string) int {
+	rank := 0
+
+	x := z.header
+	for i := z.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil &&
+			(z.less(x.level[i].forward, score, member) ||
+				(x.level[i].forward.score == score && x.level[i].forward.member == member)) {
+			rank += x.level[i].span
+			x = x.level[i].forward
+		}
+
+		if x != z.header && x.member == member {
+			return rank
+		}
+	}
+	return 0
+}
+
+// byRank returns the node at rank counting from 1.
+func (z *ZSet) byRank(rank int) *zskiplistNode {
+	traversed := 0
+
+	x := z.header
+	for i := z.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil && traversed+x.level[i].span <= rank {
+			traversed += x.level[i].span
+			x = x.level[i].forward
+		}
+
+		if traversed == rank {
+			return x
+		}
+	}
+	return nil
+}
+
+func (z *ZSet) firstInRange(r ZRange) *zskiplistNode {
+	x := z.header
+	for i := z.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil &&
+			!r.gteMin(x.level[i].forward.score, x.level[i].forward.member) {
+			x = x.level[i].forward
+		}
+	}
+
+	x = x.level[0].forward
+	if x == nil || !r.lteMax(x.score, x.member) {
+		return nil
+	}
+	return x
+}
+
+func (z *ZSet) lastInRange(r ZRange) *zskiplistNode {
+	x := z.header
+	for i := z.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil &&
+			r.lteMax(x.level[i].forward.score, x.level[i].forward.member) {
+			x = x.level[i].forward
+		}
+	}
+
+	if x == z.header || !r.gteMin(x.score, x.member) {
+		return nil
+	}
+	return x
+}
+
+// ZRange is an interval of a sorted set, either by score or by member.
+type ZRange interface {
+	gteMin(score float64, member string) bool
+	lteMax(score float64, member string) bool
+}
+
+type ScoreRange struct {
+	Min, Max     float64
+	MinEx, MaxEx bool
+}
+
+func (r ScoreRange) gteMin(score float64, _ string) bool {
+	if r.MinEx {
+		return score > r.Min
+	}
+	return score >= r.Min
+}
+
+func (r ScoreRange) lteMax(score float64, _ string) bool {
+	if r.MaxEx {
+		return score < r.Max
+	}
+	return score <= r.Max
+}
+
+// LexRange is an interval of members, which is only meaningful when all of
+// them have the same score.
+type LexRange struct {
+	Min, Max LexBound
+}
+
+// LexBound is a bound of a LexRange. Inf is -1 for the - bound and 1 for the
+// + bound, and 0 when Value is the bound.
+type LexBound struct {
+	Value     string
+	Exclusive bool
+	Inf       int
+}
+
+func (r LexRange) gteMin(_ float64, member string) bool {
+	switch {
+	case r.Min.Inf != 0:
+		return r.Min.Inf < 0
+	case r.Min.Exclusive:
+		return member > r.Min.Value
+	}
+	return member >= r.Min.Value
+}
+
+func (r LexRange) lteMax(_ float64, member string) bool {
+	switch {
+	case r.Max.Inf != 0:
+		return r.Max.Inf > 0
+	case r.Max.Exclusive:
+		return member < r.Max.Value
+	}
+	return member <= r.Max.Value
+}